@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/benmanns/goworker"
@@ -20,12 +22,34 @@ type Bench struct {
 	Result     string    `gorm:"column:result" sql:"type:json"`
 	Created_at time.Time `gorm:"column:created_at"`
 	Resultfile string    `gorm:"column:resultfile"`
+	Node       string    `gorm:"column:node"`
 }
 
 func (t *Bench) TableName() string {
 	return "bench"
 }
 
+// ワーカーの設定 (環境変数)
+//
+//	BENCH_NODE         ノード名。ポータルでチームを固定したり drain したりする時に使う (default: ホスト名)
+//	BENCH_CONCURRENCY  同時に実行するベンチマーク数 (default: 1)
+//
+// 複数のプロセスやホストで起動しても、redis からの取り出しは1件ずつなので同じジョブを二重に実行しない
+// ノード専用のキュー myqueue:<node> (webapp/node.go と同期する事)、共通のキュー myqueue の順に取り出す
+const (
+	// ポータル (webapp/main.go) と同じ loc にする事
+	portalDSN = "hisucon:KCgC6LtWKp5tpKkW#@/hisucon2019_portal?charset=utf8mb4&parseTime=True&loc=Asia%2FTokyo"
+	queueName = "myqueue"
+
+	// bench_node の更新と drain の確認の間隔
+	heartbeatInterval = 10 * time.Second
+)
+
+var (
+	nodeName    string
+	concurrency = 1
+)
+
 type Results struct {
 	Result    bool   `json:"pass"`
 	Score     int    `json:"score"`
@@ -33,25 +57,65 @@ type Results struct {
 	StartTime string `json:"start_time"`
 }
 
+// ポータルが積んだジョブの状態を job テーブルに書く (webapp/queue.go と同期する事)
+// args の3番目がジョブ ID
+func updateJob(db *gorm.DB, args []interface{}, fields map[string]interface{}) {
+	if len(args) < 3 {
+		return
+	}
+	if err := db.Table("job").Where("id = ?", fmt.Sprint(args[2])).Updates(fields).Error; err != nil {
+		fmt.Println("job status update error.", err)
+	}
+}
+
+// 実行待ちのジョブを実行中にする。ポータルの job reset で中断されていた場合は false
+func startJob(db *gorm.DB, args []interface{}) bool {
+	if len(args) < 3 {
+		return true
+	}
+	res := db.Table("job").Where("id = ? AND status = ?", fmt.Sprint(args[2]), "queued").Updates(map[string]interface{}{
+		"status":     "running",
+		"node":       nodeName,
+		"started_at": time.Now(),
+	})
+	if res.Error != nil {
+		fmt.Println("job status update error.", res.Error)
+		return true
+	}
+	return res.RowsAffected > 0
+}
+
 func myFunc(queue string, args ...interface{}) error {
 	team := fmt.Sprintf("%v", args[0])
 	ipaddress := fmt.Sprintf("%v", args[1])
 
-	db, err := gorm.Open("mysql", "hisucon:KCgC6LtWKp5tpKkW#@/hisucon2019_portal?charset=utf8mb4&parseTime=True&loc=Asia%2FTokyo")
+	db, err := gorm.Open("mysql", portalDSN)
 	if err != nil {
 		log.Fatal("DB connect error.")
 		return nil
 	}
 	defer db.Close()
 
+	if !startJob(db, args) {
+		fmt.Println("skip aborted job.", queue, args)
+		return nil
+	}
+
 	now := time.Now()
 	layout := "2006-01-02-15:04:05"
 	currentDir, _ := os.Getwd()
-	resultFile := currentDir + "/logs/" + team + "-" + ipaddress + "." + now.Format(layout) + ".result.json"
-	resultPath := "/result/" + team + "-" + ipaddress + "." + now.Format(layout) + ".result.json"
-	cmd := currentDir + "/bin/bench -remotes=" + ipaddress + " -output " + resultFile
-	fmt.Println(cmd)
-	err = exec.Command("sh", "-c", cmd).Run()
+	// 同じ時刻に別のノードや並列のワーカーで実行しても重ならないようにノード名を入れる
+	name := team + "-" + ipaddress + "." + now.Format(layout) + "." + nodeName + ".result.json"
+	resultFile := currentDir + "/logs/" + name
+	resultPath := "/result/" + name
+	benchArgs := []string{"-remotes=" + ipaddress, "-output", resultFile}
+	if concurrency > 1 {
+		// 1つのホストで複数実行すると pprof のポートが重なるので空いているポートを使う
+		benchArgs = append(benchArgs, "-pprof-port=0")
+	}
+	cmd := exec.Command(currentDir+"/bin/bench", benchArgs...)
+	fmt.Println(cmd.Args)
+	err = cmd.Run()
 	result := true
 	// 急
 	bencherror := "{\"job_id\":\"\",\"ip_addrs\":\"xx.xx.xx.xx\",\"pass\":false,\"score\":0,\"message\":\"ベンチマークの実行に失敗しました。再実行を行ってください。\",\"error\":null,\"log\":null,\"load_level\":0,\"start_time\":\"2019-08-30T07:46:57.434507962+09:00\",\"end_time\":\"2019-08-30T07:48:00.192811932+09:00\"}"
@@ -62,36 +126,113 @@ func myFunc(queue string, args ...interface{}) error {
 
 	if result {
 		jsonResult, _ := ioutil.ReadFile(resultFile)
-		var result = Bench{Team: team, Ipaddress: ipaddress, Result: string(jsonResult), Resultfile: resultPath}
+		var result = Bench{Team: team, Ipaddress: ipaddress, Result: string(jsonResult), Resultfile: resultPath, Node: nodeName}
 		db.Create(&result)
+		updateJob(db, args, map[string]interface{}{
+			"status":      "done",
+			"bench_id":    result.Id,
+			"finished_at": time.Now(),
+		})
 
 	} else {
-		var result = Bench{Team: team, Ipaddress: ipaddress, Result: bencherror}
+		var result = Bench{Team: team, Ipaddress: ipaddress, Result: bencherror, Node: nodeName}
 		db.Create(&result)
+		updateJob(db, args, map[string]interface{}{
+			"status":      "failed",
+			"bench_id":    result.Id,
+			"finished_at": time.Now(),
+		})
 
 	}
 
-	fmt.Println(queue, args[0], args[1])
-	time.Sleep(10 * time.Second)
+	fmt.Println(queue, nodeName, args[0], args[1])
 	return nil
 }
 
 func init() {
+	nodeName, _ = os.Hostname()
+	if s := os.Getenv("BENCH_NODE"); s != "" {
+		nodeName = s
+	}
+	if s := os.Getenv("BENCH_CONCURRENCY"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			log.Fatal("BENCH_CONCURRENCY must be a positive integer: ", s)
+		}
+		concurrency = n
+	}
+
+	// 固定されたチームのジョブを先に実行するように、キューは並べた順に取り出す (IsStrict: シャッフルしない)
 	settings := goworker.WorkerSettings{
 		URI:            "redis://localhost:6379/",
-		Connections:    2,
-		Queues:         []string{"myqueue", "delimited", "queues"},
+		Connections:    concurrency + 1,
+		Queues:         []string{queueName + ":" + nodeName, queueName},
 		UseNumber:      true,
 		ExitOnComplete: false,
-		Concurrency:    1,
+		Concurrency:    concurrency,
 		Namespace:      "resque:",
 		IntervalFloat:  1.0,
+		IsStrict:       true,
 	}
 	goworker.SetSettings(settings)
 	goworker.Register("Hisucon2019", myFunc)
 }
 
+// bench_node に自分を登録し、drain されているか返す
+func heartbeat(db *gorm.DB) (bool, error) {
+	err := db.Exec("INSERT INTO bench_node (name, concurrency, last_seen) VALUES (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE concurrency = VALUES(concurrency), last_seen = VALUES(last_seen)",
+		nodeName, concurrency, time.Now()).Error
+	if err != nil {
+		return false, err
+	}
+
+	var node struct {
+		Drain bool `gorm:"column:drain"`
+	}
+	if err := db.Table("bench_node").Select("drain").Where("name = ?", nodeName).Scan(&node).Error; err != nil {
+		return false, err
+	}
+	return node.Drain, nil
+}
+
 func main() {
+	db, err := gorm.Open("mysql", portalDSN)
+	if err != nil {
+		log.Fatal("DB connect error.", err)
+	}
+	defer db.Close()
+
+	// drain されている間はジョブを取らずに待つ
+	for {
+		drained, err := heartbeat(db)
+		if err != nil {
+			fmt.Println("node heartbeat error.", err)
+		}
+		if !drained {
+			break
+		}
+		time.Sleep(heartbeatInterval)
+	}
+	log.Println("Start worker", nodeName, "concurrency", concurrency)
+
+	// drain されたら goworker に終了を伝える。goworker は実行中のジョブが終わるのを待って Work から戻る
+	// systemd で再起動された後は上で drain が解除されるのを待つ
+	go func() {
+		for range time.Tick(heartbeatInterval) {
+			drained, err := heartbeat(db)
+			if err != nil {
+				fmt.Println("node heartbeat error.", err)
+				continue
+			}
+			if drained {
+				log.Println("Node", nodeName, "is drained. stop taking jobs")
+				syscall.Kill(os.Getpid(), syscall.SIGINT)
+				return
+			}
+		}
+	}()
+
 	if err := goworker.Work(); err != nil {
 		fmt.Println("Error:", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"bench/counter"
+	"bench/latency"
 	"bench/urlcache"
 )
 
@@ -34,6 +35,8 @@ var (
 	SlowThreshold          = 1000 * time.Millisecond
 	MaxCheckerRequest      = 6
 	DebugMode              = false
+	// 鮮度のあるキャッシュがあればリクエストを送らない
+	CacheSkipEnabled = true
 )
 
 var (
@@ -97,6 +100,8 @@ type CheckerTransport struct {
 }
 
 func (ct *CheckerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sentAt := time.Now()
+
 	i := getFreeHostId()
 	defer decRequestCount(i)
 
@@ -109,6 +114,8 @@ func (ct *CheckerTransport) RoundTrip(req *http.Request) (*http.Response, error)
 
 	res, err := ct.t.RoundTrip(req)
 	req.URL.Host = host
+	// 再生時に比べられるようにステータスコードも記録するので、レスポンスが返ってから書く
+	recordTrace(req, res, sentAt)
 
 	return res, err
 }
@@ -136,6 +143,25 @@ func GetLastSlowPath() (path string, t time.Time) {
 	return checkerLastSlowPath, checkerLastSlowTime
 }
 
+var (
+	numericSegmentReg = regexp.MustCompile(`/[0-9]+(/|$)`)
+)
+
+// 集計用にパスを正規化する
+// クエリ文字列を取り除き /bulletins/view/1 -> /bulletins/view/* のように ID を * にまとめる
+func NormalizePath(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if strings.HasPrefix(path, "/static/icons/") {
+		return "/static/icons/*"
+	}
+	for numericSegmentReg.MatchString(path) {
+		path = numericSegmentReg.ReplaceAllString(path, "/*$1")
+	}
+	return path
+}
+
 // 起こったら即0点にするエラー
 // 表示されているべきものが表示されていない
 // 表示されてはいけないものが表示されていないなど
@@ -152,16 +178,71 @@ func fatalErrorf(format string, a ...interface{}) error {
 	return &fatalError{fmt.Sprintf(format, a...)}
 }
 
+// エラーの分類
+type ErrorCategory string
+
+const (
+	ErrorFatal      ErrorCategory = "fatal"      // 表示内容の不備 (fatalError)
+	ErrorStatus     ErrorCategory = "status"     // 期待していないステータスコード
+	ErrorRedirect   ErrorCategory = "redirect"   // リダイレクト先の不備
+	ErrorTimeout    ErrorCategory = "timeout"    // タイムアウト
+	ErrorConnection ErrorCategory = "connection" // 接続できなかった
+	ErrorServer     ErrorCategory = "server"     // 5xx
+	ErrorCsrf       ErrorCategory = "csrf"       // csrf_token の取得や検証の失敗
+	ErrorOther      ErrorCategory = "other"
+)
+
+type categorizedError struct {
+	category ErrorCategory
+	msg      string
+}
+
+func (e *categorizedError) Error() string {
+	return e.msg
+}
+
+func categoryErrorf(category ErrorCategory, format string, a ...interface{}) error {
+	return &categorizedError{category, fmt.Sprintf(format, a...)}
+}
+
+func errorCategoryOf(err error) ErrorCategory {
+	switch e := err.(type) {
+	case *fatalError:
+		return ErrorFatal
+	case *categorizedError:
+		return e.category
+	}
+	if err == RequestTimeoutError {
+		return ErrorTimeout
+	}
+	return ErrorOther
+}
+
 type CheckerError struct {
-	t      time.Time
-	err    error
-	method string
-	path   string
-	query  string
+	t           time.Time
+	err         error
+	method      string
+	path        string
+	query       string
+	category    ErrorCategory
+	description string
+	// 分散実行でエージェントから集めたエラーの場合はそのノード名
+	node string
 }
 
 func (e *CheckerError) Error() string {
-	return fmt.Sprintf("%v %v (%v %v %v)", e.t, e.err, e.method, e.path, e.query)
+	msg := fmt.Sprintf("%v %v (%v %v %v)", e.t, e.err, e.method, e.path, e.query)
+	if e.description != "" {
+		msg += fmt.Sprintf(" [%v]", e.description)
+	}
+	if e.node != "" {
+		msg += " @" + e.node
+	}
+	return msg
+}
+
+func (e *CheckerError) Category() ErrorCategory {
+	return e.category
 }
 
 func (e *CheckerError) IsFatal() bool {
@@ -173,6 +254,49 @@ func (e *CheckerError) IsTimeout() bool {
 	return e.err == RequestTimeoutError
 }
 
+func isConnectionError(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	oe, ok := err.(*net.OpError)
+	return ok && oe.Op == "dial"
+}
+
+// csrf_token を送った POST が 400 になった場合は CSRF の検証に失敗したとみなす
+// アイコンのアップロードなど multipart の POST は、ボディに csrf_token のフィールドがある場合だけ
+func isCsrfFailure(a *CheckAction, res *http.Response) bool {
+	if res.StatusCode != http.StatusBadRequest || a.Method != "POST" {
+		return false
+	}
+	return a.sentCsrfToken
+}
+
+// リクエストに csrf_token が含まれているか
+// PostBody は送った後には読めないので、送る前に呼ぶ
+func hasCsrfToken(a *CheckAction) bool {
+	if _, ok := a.PostData["csrf_token"]; ok {
+		return true
+	}
+	if b, ok := a.PostBody.(*bytes.Buffer); ok {
+		return bytes.Contains(b.Bytes(), []byte(`name="csrf_token"`))
+	}
+	return false
+}
+
+func statusError(a *CheckAction, res *http.Response, msg string) error {
+	if isCsrfFailure(a, res) {
+		return categoryErrorf(ErrorCsrf, "%s", msg)
+	}
+	return categoryErrorf(ErrorStatus, "%s", msg)
+}
+
+func classifyCheckFuncError(a *CheckAction, res *http.Response, err error) error {
+	if e, ok := err.(*categorizedError); ok && e.category == ErrorStatus {
+		return statusError(a, res, e.msg)
+	}
+	return err
+}
+
 func appendError(err *CheckerError) {
 	checkerMtx.Lock()
 	if !checkerErrorGuard {
@@ -209,12 +333,71 @@ func GetCheckerErrors() []error {
 	return errs
 }
 
+// エラー数とそのうちのタイムアウト数を返す
+func GetCheckerErrorCount() (total int, timeouts int) {
+	checkerMtx.Lock()
+	defer checkerMtx.Unlock()
+
+	for _, e := range checkerErrors {
+		if e.IsTimeout() {
+			timeouts++
+		}
+	}
+	return len(checkerErrors), timeouts
+}
+
+type ErrorSample struct {
+	Time        time.Time     `json:"time"`
+	Category    ErrorCategory `json:"category"`
+	Method      string        `json:"method"`
+	Path        string        `json:"path"`
+	Description string        `json:"description"`
+	Message     string        `json:"message"`
+}
+
+type ErrorReport struct {
+	Total      int                   `json:"total"`
+	ByCategory map[ErrorCategory]int `json:"by_category"`
+	ByEndpoint map[string]int        `json:"by_endpoint"`
+	Samples    []*ErrorSample        `json:"samples"`
+}
+
+// 分類ごと・エンドポイントごとのエラー数と、分類ごとに最大 samplesPerCategory 件のエラー内容を返す
+func GetErrorReport(samplesPerCategory int) *ErrorReport {
+	checkerMtx.Lock()
+	defer checkerMtx.Unlock()
+
+	r := &ErrorReport{
+		Total:      len(checkerErrors),
+		ByCategory: map[ErrorCategory]int{},
+		ByEndpoint: map[string]int{},
+		Samples:    []*ErrorSample{},
+	}
+	for _, e := range checkerErrors {
+		r.ByCategory[e.category]++
+		r.ByEndpoint[e.method+"|"+NormalizePath(e.path)]++
+		if r.ByCategory[e.category] <= samplesPerCategory {
+			r.Samples = append(r.Samples, &ErrorSample{
+				Time:        e.t,
+				Category:    e.category,
+				Method:      e.method,
+				Path:        e.path,
+				Description: e.description,
+				Message:     e.err.Error(),
+			})
+		}
+	}
+	return r
+}
+
 type Checker struct {
 	Client *http.Client
 	Cache  *urlcache.CacheStore
 
 	chRequestToken chan int
 	debugHeaders   map[string]string
+	userName       string
+	csrf           csrfTokenCache
 }
 
 type CheckAction struct {
@@ -233,9 +416,18 @@ type CheckAction struct {
 	Description        string
 	CheckFunc          func(*http.Response, *bytes.Buffer) error
 
-	EnableCache          bool
+	// ETag / Last-Modified で再検証する
+	EnableCache bool
+	// 鮮度のあるキャッシュがあればリクエストを送らない (immutable なものは指定しなくても送らない)
 	SkipIfCacheAvailable bool
 	DisableSlowChecking  bool
+
+	// レスポンスから取り出す値。結果の Captures に名前で入る
+	Captures []*Capture
+	// 結果の Body にレスポンスのボディを残す (Captures を指定した場合は常に残す)
+	KeepBody bool
+
+	sentCsrfToken bool
 }
 
 func NewChecker() *Checker {
@@ -270,6 +462,7 @@ func (c *Checker) ResetCookie() {
 		log.Fatalln(err)
 	}
 	c.Client.Jar = jar
+	c.csrf.clear()
 }
 
 func (c *Checker) OnError(a *CheckAction, req *http.Request, err error) error {
@@ -278,17 +471,33 @@ func (c *Checker) OnError(a *CheckAction, req *http.Request, err error) error {
 		return err
 	}
 
-	var cerr *CheckerError
-	if req == nil {
-		cerr = &CheckerError{time.Now(), err, a.Method, a.Path, ""}
-	} else {
-		cerr = &CheckerError{time.Now(), err, req.Method, req.URL.Path, req.URL.Query().Encode()}
+	cerr := &CheckerError{
+		t:           time.Now(),
+		err:         err,
+		method:      a.Method,
+		path:        a.Path,
+		category:    errorCategoryOf(err),
+		description: a.Description,
+	}
+	if req != nil {
+		cerr.method = req.Method
+		cerr.path = req.URL.Path
+		cerr.query = req.URL.Query().Encode()
 	}
 
 	appendError(cerr)
 	return cerr
 }
 
+// Play の途中で発生したエラーは HAR にも記録する
+func (c *Checker) onPlayError(a *CheckAction, req *http.Request, res *http.Response, body *bytes.Buffer, start time.Time, err error) error {
+	recordHAR(a, req, res, body, start, err)
+	if res != nil && res.Request != nil {
+		req = res.Request
+	}
+	return c.OnError(a, req, err)
+}
+
 func (c *Checker) NewRequest(method, uri string, body io.Reader) (*http.Request, error) {
 	parsedURL, err := url.Parse(uri)
 
@@ -311,170 +520,44 @@ func (c *Checker) NewRequest(method, uri string, body io.Reader) (*http.Request,
 	return req, err
 }
 
+// Play は PlayWithResult の結果を使わない場合の省略形
 func (c *Checker) Play(ctx context.Context, a *CheckAction) error {
-	if ctx.Err() != nil {
-		return nil
-	}
-
-	select {
-	case token := <-c.chRequestToken:
-		defer func() {
-			c.chRequestToken <- token
-		}()
-	case <-ctx.Done():
-		GuardCheckerError(true)
-		return nil
-	}
-
-	var req *http.Request
-	var err error
-
-	if strings.ToUpper(a.Method) == "POST" {
-		if a.PostBody != nil {
-			req, err = c.NewRequest(a.Method, a.Path, a.PostBody)
-			if req != nil {
-				req.Header.Set("Content-Type", a.ContentType)
-			}
-		} else {
-			formData := url.Values{}
-			for key, val := range a.PostData {
-				formData.Set(key, val)
-			}
-			buf := bytes.NewBufferString(formData.Encode())
-			req, err = c.NewRequest(a.Method, a.Path, buf)
-			if req != nil {
-				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-			}
-		}
-	} else {
-		req, err = c.NewRequest(a.Method, a.Path, nil)
-	}
-
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return c.OnError(a, req, fmt.Errorf("リクエストに失敗しました (主催者に連絡してください)"))
-	}
-
-	if DebugMode {
-		for k, v := range c.debugHeaders {
-			req.Header.Set(k, v)
-		}
-		cnt := atomic.AddInt32(&checkerRequestCounter, 1)
-		req.Header.Set("X-Request-ID", fmt.Sprint(cnt))
-	}
-
-	if a.EnableCache {
-		if cache, found := gCache.Get(a.Path); found {
-			cache.ApplyRequest(req)
-		} else if cache, found := c.Cache.Get(a.Path); found {
-			cache.ApplyRequest(req)
-		}
-	}
-
-	req.Header.Set("User-Agent", UserAgent)
-	for key, val := range a.Headers {
-		log.Println(key, val)
-		req.Header.Add(key, val)
-	}
-
-	timeout := GetTimeout
-	if req.Method == http.MethodPost {
-		timeout = PostTimeout
-	}
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-	req = req.WithContext(ctx)
-
-	tm := time.AfterFunc(SlowThreshold, func() {
-		if !a.DisableSlowChecking {
-			updateLastSlowPath(a.Path)
-		}
-	})
-	res, err := c.Client.Do(req)
-	tm.Stop()
-
-	select {
-	case <-ctx.Done():
-		GuardCheckerError(true)
-	default:
-
-	}
-
-	isRedirectErr := false
-	if urlError, ok := err.(*url.Error); ok && urlError.Err == RedirectAttemptedError {
-		isRedirectErr = true
-	}
-
-	if err != nil && !isRedirectErr {
-		switch e := err.(type) {
-		case net.Error:
-			if e.Timeout() {
-				return c.OnError(a, req, RequestTimeoutError)
-			}
-		}
-
-		return c.OnError(a, req, fmt.Errorf("リクエストに失敗しました %v", err))
-	}
-
-	if res == nil {
-		return c.OnError(a, req, fmt.Errorf("レスポンスが不正です"))
-	}
-
-	defer res.Body.Close()
-
-	body := GetBuffer()
-	defer PutBuffer(body)
-
-	_, err = io.Copy(body, res.Body)
-	if err == context.DeadlineExceeded {
-		return c.OnError(a, req, RequestTimeoutError)
-	}
-	// Note. リダイレクトなどのときはbodyが既に閉じられている状態で来て closed error が返るので無視する
+	_, err := c.PlayWithResult(ctx, a)
+	return err
+}
 
-	if 500 <= res.StatusCode {
-		return c.OnError(a, res.Request, fmt.Errorf("サーバエラーが発生しました。%s", res.Status))
+// Client が送る時と同じように Jar の Cookie を付けたリクエストのコピー
+// キャッシュに保存する Vary の値は送ったリクエスト (Cookie 付き) から取るので、探す時も揃える
+func (c *Checker) withJarCookies(req *http.Request) *http.Request {
+	if c.Client.Jar == nil {
+		return req
 	}
-
-	if a.ExpectedStatusCode != 0 && res.StatusCode != a.ExpectedStatusCode {
-		return c.OnError(a, res.Request, fmt.Errorf("Response code should be %d, got %d, data: %s", a.ExpectedStatusCode, res.StatusCode, a.PostData))
+	cookies := c.Client.Jar.Cookies(req.URL)
+	if len(cookies) == 0 {
+		return req
 	}
-
-	if a.ExpectedLocation != nil {
-		l := res.Header["Location"]
-		if len(l) != 1 {
-			return c.OnError(a, res.Request, fmt.Errorf("リダイレクトURLが適切に設定されていません"))
-		}
-		u, err := url.Parse(l[0])
-		if err != nil || !a.ExpectedLocation.MatchString(u.Path) {
-			return c.OnError(a, res.Request, fmt.Errorf("リダイレクト先URLが正しくありません: expected '%s', got '%s'", a.ExpectedLocation, l[0]))
-		}
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
 	}
-
-	if res.StatusCode == 200 && a.EnableCache {
-		cache, _ := urlcache.NewURLCache(res, body)
-		if cache != nil {
-			c.Cache.Set(a.Path, cache)
-			if cache.CacheControl.Public() {
-				gCache.Set(a.Path, cache)
-			}
-		}
-	}
-	if a.CheckFunc != nil {
-		if err := a.CheckFunc(res, body); err != nil {
-			if a.EnableCache {
-				c.Cache.Del(a.Path)
-			}
-			return c.OnError(a, res.Request, err)
-		}
+	for _, ck := range cookies {
+		r.AddCookie(ck)
 	}
-
-	counter.IncKey(a.Method + "|" + a.Path)
-	return nil
+	return r
 }
 
-func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (string, error) {
+// リクエストを送り、CheckAction の期待値を検証して結果を返す
+// ctx が終了している場合はリクエストを送らず、Skipped の結果を返す
+// 鮮度のあるキャッシュを使った場合もリクエストを送らず、Cached の結果を返す
+// エラーの場合も nil ではない結果を返すが、中身は途中までしか埋まっていない
+func (c *Checker) PlayWithResult(ctx context.Context, a *CheckAction) (*PlayResult, error) {
+	result := &PlayResult{}
+
 	if ctx.Err() != nil {
-		return "", nil
+		result.Skipped = true
+		return result, nil
 	}
 
 	select {
@@ -484,13 +567,15 @@ func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (strin
 		}()
 	case <-ctx.Done():
 		GuardCheckerError(true)
-		return "", nil
+		result.Skipped = true
+		return result, nil
 	}
 
 	var req *http.Request
 	var err error
 
 	if strings.ToUpper(a.Method) == "POST" {
+		a.sentCsrfToken = hasCsrfToken(a)
 		if a.PostBody != nil {
 			req, err = c.NewRequest(a.Method, a.Path, a.PostBody)
 			if req != nil {
@@ -513,7 +598,7 @@ func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (strin
 
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		return "", c.OnError(a, req, fmt.Errorf("リクエストに失敗しました (主催者に連絡してください)"))
+		return result, c.OnError(a, req, fmt.Errorf("リクエストに失敗しました (主催者に連絡してください)"))
 	}
 
 	if DebugMode {
@@ -524,28 +609,42 @@ func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (strin
 		req.Header.Set("X-Request-ID", fmt.Sprint(cnt))
 	}
 
-	if a.EnableCache {
-		if cache, found := gCache.Get(a.Path); found {
-			cache.ApplyRequest(req)
-		} else if cache, found := c.Cache.Get(a.Path); found {
-			cache.ApplyRequest(req)
-		}
-	}
-
 	req.Header.Set("User-Agent", UserAgent)
 	for key, val := range a.Headers {
 		log.Println(key, val)
 		req.Header.Add(key, val)
 	}
 
+	// Vary を見るのでヘッダを設定し終わってから探す
+	var cache *urlcache.URLCache
+	var cacheStore *urlcache.CacheStore
+	if a.EnableCache {
+		shared := false
+		varyReq := c.withJarCookies(req)
+		if v, found := gCache.Get(a.Path); found && v.Matches(varyReq) {
+			cache, cacheStore, shared = v, gCache, true
+		} else if v, found := c.Cache.Get(a.Path); found && v.Matches(varyReq) {
+			cache, cacheStore = v, c.Cache
+		}
+		if cache != nil {
+			if CacheSkipEnabled && (a.SkipIfCacheAvailable || cache.Immutable()) && cache.Fresh(time.Now(), shared) {
+				counter.IncKey("SKIP|" + a.Path)
+				result.Cached = true
+				return result, nil
+			}
+			cache.ApplyRequest(req)
+		}
+	}
+
 	timeout := GetTimeout
 	if req.Method == http.MethodPost {
 		timeout = PostTimeout
 	}
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	req = req.WithContext(ctx)
+	req = req.WithContext(context.WithValue(ctx, traceUserKey{}, c.userName))
 
+	start := time.Now()
 	tm := time.AfterFunc(SlowThreshold, func() {
 		if !a.DisableSlowChecking {
 			updateLastSlowPath(a.Path)
@@ -566,19 +665,28 @@ func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (strin
 		isRedirectErr = true
 	}
 
+	latencyKey := req.Method + "|" + NormalizePath(a.Path)
 	if err != nil && !isRedirectErr {
+		// 遅いリクエストほどここに来るので、レスポンスがなくてもヒストグラムに入れる
 		switch e := err.(type) {
 		case net.Error:
 			if e.Timeout() {
-				return "", c.OnError(a, req, RequestTimeoutError)
+				result.Latency = timeout
+				latency.Record(latencyKey, result.Latency)
+				return result, c.onPlayError(a, req, nil, nil, start, RequestTimeoutError)
 			}
 		}
+		result.Latency = time.Since(start)
+		latency.Record(latencyKey, result.Latency)
+		if isConnectionError(err) {
+			return result, c.onPlayError(a, req, nil, nil, start, categoryErrorf(ErrorConnection, "リクエストに失敗しました %v", err))
+		}
 
-		return "", c.OnError(a, req, fmt.Errorf("リクエストに失敗しました %v", err))
+		return result, c.onPlayError(a, req, nil, nil, start, fmt.Errorf("リクエストに失敗しました %v", err))
 	}
 
 	if res == nil {
-		return "", c.OnError(a, req, fmt.Errorf("レスポンスが不正です"))
+		return result, c.onPlayError(a, req, nil, nil, start, fmt.Errorf("レスポンスが不正です"))
 	}
 
 	defer res.Body.Close()
@@ -586,50 +694,84 @@ func (c *Checker) PlayReturnRedirect(ctx context.Context, a *CheckAction) (strin
 	body := GetBuffer()
 	defer PutBuffer(body)
 
+	result.StatusCode = res.StatusCode
+	result.Header = res.Header
+	result.Location = res.Header.Get("Location")
+
+	// セッションが変わった可能性があるので csrf_token を取り直す
+	if len(res.Header["Set-Cookie"]) > 0 || isCsrfFailure(a, res) {
+		c.csrf.clear()
+	}
+
 	_, err = io.Copy(body, res.Body)
+	result.Latency = time.Since(start)
+	latency.Record(latencyKey, result.Latency)
 	if err == context.DeadlineExceeded {
-		return "", c.OnError(a, req, RequestTimeoutError)
+		return result, c.onPlayError(a, req, res, body, start, RequestTimeoutError)
 	}
 	// Note. リダイレクトなどのときはbodyが既に閉じられている状態で来て closed error が返るので無視する
 
 	if 500 <= res.StatusCode {
-		return "", c.OnError(a, res.Request, fmt.Errorf("サーバエラーが発生しました。%s", res.Status))
+		return result, c.onPlayError(a, req, res, body, start, categoryErrorf(ErrorServer, "サーバエラーが発生しました。%s", res.Status))
 	}
 
 	if a.ExpectedStatusCode != 0 && res.StatusCode != a.ExpectedStatusCode {
-		return "", c.OnError(a, res.Request, fmt.Errorf("Response code should be %d, got %d, data: %s", a.ExpectedStatusCode, res.StatusCode, a.PostData))
+		return result, c.onPlayError(a, req, res, body, start, statusError(a, res, fmt.Sprintf("Response code should be %d, got %d, data: %s", a.ExpectedStatusCode, res.StatusCode, a.PostData)))
 	}
 
-	redirect_path := res.Header["Location"][0]
 	if a.ExpectedLocation != nil {
 		l := res.Header["Location"]
 		if len(l) != 1 {
-			return "", c.OnError(a, res.Request, fmt.Errorf("リダイレクトURLが適切に設定されていません"))
+			return result, c.onPlayError(a, req, res, body, start, categoryErrorf(ErrorRedirect, "リダイレクトURLが適切に設定されていません"))
 		}
 		u, err := url.Parse(l[0])
 		if err != nil || !a.ExpectedLocation.MatchString(u.Path) {
-			return "", c.OnError(a, res.Request, fmt.Errorf("リダイレクト先URLが正しくありません: expected '%s', got '%s'", a.ExpectedLocation, l[0]))
+			return result, c.onPlayError(a, req, res, body, start, categoryErrorf(ErrorRedirect, "リダイレクト先URLが正しくありません: expected '%s', got '%s'", a.ExpectedLocation, l[0]))
 		}
 	}
 
 	if res.StatusCode == 200 && a.EnableCache {
-		cache, _ := urlcache.NewURLCache(res, body)
+		cache, _ := urlcache.NewURLCache(res, body, start)
 		if cache != nil {
 			c.Cache.Set(a.Path, cache)
-			if cache.CacheControl.Public() {
+			if cache.Public() {
 				gCache.Set(a.Path, cache)
 			}
+		} else {
+			// no-store などに変わった場合は古いものを使わない
+			c.Cache.Del(a.Path)
+			gCache.Del(a.Path)
 		}
+	} else if res.StatusCode == http.StatusNotModified && cache != nil {
+		cacheStore.Set(a.Path, cache.Refresh(res, start))
+	}
+	// CheckFunc が body を読み切ってしまうので先にコピーしておく
+	if a.KeepBody || len(a.Captures) > 0 {
+		result.Body = append([]byte(nil), body.Bytes()...)
 	}
+
 	if a.CheckFunc != nil {
 		if err := a.CheckFunc(res, body); err != nil {
+			// 内容が正しくないものを他の Checker でも使わないように共有キャッシュからも消す
 			if a.EnableCache {
 				c.Cache.Del(a.Path)
+				gCache.Del(a.Path)
+			}
+			return result, c.onPlayError(a, req, res, body, start, classifyCheckFuncError(a, res, err))
+		}
+	}
+
+	if len(a.Captures) > 0 {
+		result.Captures = map[string]string{}
+		for _, cp := range a.Captures {
+			v, err := cp.extract(res, result)
+			if err != nil {
+				return result, c.onPlayError(a, req, res, body, start, err)
 			}
-			return "", c.OnError(a, res.Request, err)
+			result.Captures[cp.Name] = v
 		}
 	}
 
 	counter.IncKey(a.Method + "|" + a.Path)
-	return redirect_path, nil
+	return result, nil
 }
@@ -0,0 +1,97 @@
+package bench
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	"bench/urlcache"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"/login", "/login"},
+		{"/bulletins?page=3", "/bulletins"},
+		{"/bulletins/view/12", "/bulletins/view/*"},
+		{"/bulletins/view/12#comment", "/bulletins/view/*"},
+		{"/bulletins/12/edit", "/bulletins/*/edit"},
+		{"/a/1/2/3", "/a/*/*/*"},
+		{"/static/icons/abe.png", "/static/icons/*"},
+		{"/static/css/main.css", "/static/css/main.css"},
+		{"/users/1a", "/users/1a"},
+	}
+	for _, tt := range tests {
+		if got := NormalizePath(tt.path); got != tt.want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func multipartBody(fields map[string]string) *bytes.Buffer {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	w.Close()
+	return body
+}
+
+func TestIsCsrfFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		action *CheckAction
+		status int
+		want   bool
+	}{
+		{"form with token", &CheckAction{Method: "POST", PostData: map[string]string{"csrf_token": "x"}}, 400, true},
+		{"form without token", &CheckAction{Method: "POST", PostData: map[string]string{"title": "x"}}, 400, false},
+		{"form with token 409", &CheckAction{Method: "POST", PostData: map[string]string{"csrf_token": "x"}}, 409, false},
+		{"multipart with token", &CheckAction{Method: "POST", PostBody: multipartBody(map[string]string{"csrf_token": "x"})}, 400, true},
+		{"multipart without token", &CheckAction{Method: "POST", PostBody: multipartBody(map[string]string{"nickname": "x"})}, 400, false},
+		{"get", &CheckAction{Method: "GET"}, 400, false},
+	}
+	for _, tt := range tests {
+		if tt.action.Method == "POST" {
+			tt.action.sentCsrfToken = hasCsrfToken(tt.action)
+		}
+		res := &http.Response{StatusCode: tt.status}
+		if got := isCsrfFailure(tt.action, res); got != tt.want {
+			t.Errorf("%s: isCsrfFailure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithJarCookiesMatchesVary(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	c := &Checker{Client: &http.Client{Jar: jar}}
+	u, _ := url.Parse("http://example.com/bulletins")
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s1"}})
+
+	// Client が実際に送ったリクエスト (Jar の Cookie が付いている)
+	sent, _ := http.NewRequest("GET", u.String(), nil)
+	sent.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	res := &http.Response{StatusCode: 200, Header: http.Header{"Etag": {`"v1"`}, "Vary": {"Cookie"}}, Request: sent}
+	cache, _ := urlcache.NewURLCache(res, bytes.NewBufferString("body"), time.Now())
+
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	if !cache.Matches(c.withJarCookies(req)) {
+		t.Errorf("request with the same session should match")
+	}
+	if req.Header.Get("Cookie") != "" {
+		t.Errorf("withJarCookies should not modify the original request")
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s2"}})
+	if cache.Matches(c.withJarCookies(req)) {
+		t.Errorf("request with another session should not match")
+	}
+}
@@ -27,6 +27,12 @@ func AddKey(key string, diff int) {
 	mtx.Unlock()
 }
 
+func Reset() {
+	mtx.Lock()
+	cntMap = map[string]int64{}
+	mtx.Unlock()
+}
+
 func GetKey(key string) int64 {
 	mtx.Lock()
 	v := cntMap[key]
@@ -0,0 +1,142 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// csrf_token の取得
+//
+// ページの構成に依存しないよう、次の順に探して最初に見つかった値を使う
+//   1. フォームの hidden input (name が csrfTokenNames のいずれか)
+//   2. <meta name="csrf-token" content="..."> など
+//   3. X-CSRF-Token などのレスポンスヘッダ
+//   4. csrf_token, XSRF-TOKEN などの Cookie
+//
+// -csrf-cache を指定した場合、取得したトークンは Checker ごとにキャッシュし、同じセッションの間は再利用する
+// キャッシュするとフォームのページの GET が減り、その分スコアと負荷のかかり方が変わるので既定では無効
+// 次の場合はキャッシュを捨てて、次回はページから取り直す
+//   - レスポンスで Cookie が変更された (ログイン・ログアウトでセッションが変わった可能性がある)
+//   - csrf_token を送った POST が 400 になった
+//   - ResetCookie した
+//   - csrfTokenTTL を過ぎた (Flask-WTF のトークンの有効期限は 1 時間)
+
+var (
+	CsrfTokenCacheEnabled = false
+
+	csrfTokenTTL = 30 * time.Minute
+
+	csrfTokenNames  = []string{"csrf_token", "_csrf_token", "csrfmiddlewaretoken", "authenticity_token", "_token"}
+	csrfMetaNames   = []string{"csrf-token", "csrf_token", "_csrf"}
+	csrfHeaderNames = []string{"X-CSRF-Token", "X-CSRFToken", "X-XSRF-Token"}
+	csrfCookieNames = []string{"csrf_token", "XSRF-TOKEN", "csrftoken", "_csrf"}
+)
+
+type csrfTokenCache struct {
+	mtx       sync.Mutex
+	token     string
+	fetchedAt time.Time
+}
+
+func (c *csrfTokenCache) get() (string, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.token == "" || time.Since(c.fetchedAt) > csrfTokenTTL {
+		return "", false
+	}
+	return c.token, true
+}
+
+func (c *csrfTokenCache) set(token string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.token = token
+	c.fetchedAt = time.Now()
+}
+
+func (c *csrfTokenCache) clear() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.token = ""
+}
+
+func findCsrfToken(res *http.Response, doc *goquery.Document, cookies []*http.Cookie) string {
+	if doc != nil {
+		for _, name := range csrfTokenNames {
+			token := ""
+			doc.Find(`input[name="` + name + `"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+				token, _ = s.Attr("value")
+				return token == ""
+			})
+			if token != "" {
+				return token
+			}
+		}
+		for _, name := range csrfMetaNames {
+			if token, _ := doc.Find(`meta[name="` + name + `"]`).First().Attr("content"); token != "" {
+				return token
+			}
+		}
+	}
+
+	for _, name := range csrfHeaderNames {
+		if token := res.Header.Get(name); token != "" {
+			return token
+		}
+	}
+
+	// Set-Cookie されたものを優先し、なければ保存済みの Cookie から探す
+	cookies = append(res.Cookies(), cookies...)
+	for _, name := range csrfCookieNames {
+		for _, ck := range cookies {
+			if ck.Name == name && ck.Value != "" {
+				return ck.Value
+			}
+		}
+	}
+	return ""
+}
+
+func getCsrfToken(checker *Checker, ctx context.Context, url string) (csrf_token string, err error) {
+	if CsrfTokenCacheEnabled {
+		if token, ok := checker.csrf.get(); ok {
+			return token, nil
+		}
+	}
+
+	result, err := checker.PlayWithResult(ctx, &CheckAction{
+		Method:             "GET",
+		Path:               url,
+		ExpectedStatusCode: 200,
+		CheckFunc: func(res *http.Response, body *bytes.Buffer) error {
+			// HTML でなくてもヘッダや Cookie から探すので、パースできなくてもエラーにしない
+			doc, _ := goquery.NewDocumentFromReader(body)
+			var cookies []*http.Cookie
+			if checker.Client.Jar != nil {
+				cookies = checker.Client.Jar.Cookies(res.Request.URL)
+			}
+			csrf_token = findCsrfToken(res, doc, cookies)
+			if csrf_token == "" {
+				return categoryErrorf(ErrorCsrf, "csrf_tokenが取得できませんでした")
+			}
+			return nil
+		},
+		Description: "csrf_tokenを取得",
+	})
+	if err != nil || result.Skipped {
+		return "", err
+	}
+
+	if CsrfTokenCacheEnabled {
+		checker.csrf.set(csrf_token)
+	}
+	return csrf_token, nil
+}
@@ -0,0 +1,48 @@
+package bench
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestFindCsrfToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		html    string
+		header  http.Header
+		cookies []*http.Cookie
+		want    string
+	}{
+		{"hidden input", `<form><input type="hidden" name="csrf_token" value="form-token"></form>`, nil, nil, "form-token"},
+		{"skip empty input", `<form><input name="csrf_token" value=""><input name="csrf_token" value="second"></form>`, nil, nil, "second"},
+		{"other input name", `<form><input name="authenticity_token" value="rails"></form>`, nil, nil, "rails"},
+		{"input before meta", `<meta name="csrf-token" content="meta-token"><input name="csrf_token" value="form-token">`, nil, nil, "form-token"},
+		{"meta", `<head><meta name="csrf-token" content="meta-token"></head>`, nil, nil, "meta-token"},
+		{"header", `<p>no form</p>`, http.Header{"X-Csrf-Token": {"header-token"}}, nil, "header-token"},
+		{"set-cookie before jar", ``, http.Header{"Set-Cookie": {"XSRF-TOKEN=new-token"}}, []*http.Cookie{{Name: "XSRF-TOKEN", Value: "old-token"}}, "new-token"},
+		{"jar cookie", ``, nil, []*http.Cookie{{Name: "session", Value: "s"}, {Name: "csrftoken", Value: "jar-token"}}, "jar-token"},
+		{"not found", `<form><input name="title" value="x"></form>`, nil, []*http.Cookie{{Name: "session", Value: "s"}}, ""},
+	}
+	for _, tt := range tests {
+		res := &http.Response{Header: tt.header}
+		if res.Header == nil {
+			res.Header = http.Header{}
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(tt.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := findCsrfToken(res, doc, tt.cookies); got != tt.want {
+			t.Errorf("%s: findCsrfToken = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// HTML でないレスポンスでもヘッダから探す
+	res := &http.Response{Header: http.Header{"X-Csrftoken": {"no-doc"}}}
+	if got := findCsrfToken(res, nil, nil); got != "no-doc" {
+		t.Errorf("without document: findCsrfToken = %q, want %q", got, "no-doc")
+	}
+}
@@ -0,0 +1,250 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// エラーになったリクエストとレスポンスを HAR 1.2 形式で保存する
+// http://www.softwareishard.com/blog/har-12-spec/
+
+var (
+	HARMaxEntries  = 200
+	HARMaxBodySize = 64 * 1024
+
+	harMtx     sync.Mutex
+	harEnabled bool
+	harEntries []*HAREntry
+)
+
+type HAR struct {
+	Log *HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator *HARCreator `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time    `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *HARRequest  `json:"request"`
+	Response        *HARResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *HARTimings  `json:"timings"`
+	Comment         string       `json:"comment,omitempty"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*HARNameValue `json:"cookies"`
+	Headers     []*HARNameValue `json:"headers"`
+	QueryString []*HARNameValue `json:"queryString"`
+	PostData    *HARPostData    `json:"postData,omitempty"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HARResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*HARNameValue `json:"cookies"`
+	Headers     []*HARNameValue `json:"headers"`
+	Content     *HARContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func EnableHAR(enable bool) {
+	harMtx.Lock()
+	harEnabled = enable
+	harMtx.Unlock()
+}
+
+func harHeaders(h http.Header) []*HARNameValue {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := []*HARNameValue{}
+	for _, k := range keys {
+		for _, v := range h[k] {
+			ret = append(ret, &HARNameValue{k, v})
+		}
+	}
+	return ret
+}
+
+func harCookies(cookies []*http.Cookie) []*HARNameValue {
+	ret := []*HARNameValue{}
+	for _, c := range cookies {
+		ret = append(ret, &HARNameValue{c.Name, c.Value})
+	}
+	return ret
+}
+
+func harTruncate(b []byte) ([]byte, bool) {
+	if len(b) > HARMaxBodySize {
+		return b[:HARMaxBodySize], true
+	}
+	return b, false
+}
+
+// res が nil の場合 (タイムアウトなど) は status 0 のレスポンスとして記録する
+func recordHAR(a *CheckAction, req *http.Request, res *http.Response, body *bytes.Buffer, start time.Time, err error) {
+	harMtx.Lock()
+	enabled := harEnabled && len(harEntries) < HARMaxEntries
+	harMtx.Unlock()
+	if !enabled || req == nil {
+		return
+	}
+
+	checkerMtx.Lock()
+	guard := checkerErrorGuard
+	checkerMtx.Unlock()
+	if guard {
+		return
+	}
+
+	elapsed := float64(time.Since(start)) / float64(time.Millisecond)
+
+	hreq := &HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: []*HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			hreq.QueryString = append(hreq.QueryString, &HARNameValue{k, v})
+		}
+	}
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			hreq.BodySize = len(b)
+			b, _ = harTruncate(b)
+			hreq.PostData = &HARPostData{
+				MimeType: req.Header.Get("Content-Type"),
+				Text:     string(b),
+			}
+		}
+	}
+
+	hres := &HARResponse{
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []*HARNameValue{},
+		Headers:     []*HARNameValue{},
+		Content:     &HARContent{MimeType: "x-unknown"},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if res != nil {
+		hres.Status = res.StatusCode
+		hres.StatusText = http.StatusText(res.StatusCode)
+		hres.HTTPVersion = res.Proto
+		hres.Cookies = harCookies(res.Cookies())
+		hres.Headers = harHeaders(res.Header)
+		hres.RedirectURL = res.Header.Get("Location")
+		if ct := res.Header.Get("Content-Type"); ct != "" {
+			hres.Content.MimeType = ct
+		}
+	}
+	if body != nil {
+		b := body.Bytes()
+		hres.BodySize = len(b)
+		hres.Content.Size = len(b)
+		b, truncated := harTruncate(b)
+		if utf8.Valid(b) {
+			hres.Content.Text = string(b)
+		} else {
+			hres.Content.Text = base64.StdEncoding.EncodeToString(b)
+			hres.Content.Encoding = "base64"
+		}
+		if truncated {
+			hres.Content.Comment = "truncated"
+		}
+	}
+
+	entry := &HAREntry{
+		StartedDateTime: start,
+		Time:            elapsed,
+		Request:         hreq,
+		Response:        hres,
+		Timings:         &HARTimings{Send: 0, Wait: elapsed, Receive: 0},
+		Comment:         a.Description + ": " + err.Error(),
+	}
+
+	harMtx.Lock()
+	if len(harEntries) < HARMaxEntries {
+		harEntries = append(harEntries, entry)
+	}
+	harMtx.Unlock()
+}
+
+func WriteHARFile(path string) error {
+	harMtx.Lock()
+	h := &HAR{
+		Log: &HARLog{
+			Version: "1.2",
+			Creator: &HARCreator{Name: UserAgent, Version: "1.0"},
+			Entries: append([]*HAREntry{}, harEntries...),
+		},
+	}
+	harMtx.Unlock()
+
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,217 @@
+package latency
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// レスポンスタイムのヒストグラム
+// 1s までは 1ms 刻み、それ以降は 10ms 刻みのバケットに数える
+const (
+	fineLimit   = time.Second
+	fineWidth   = time.Millisecond
+	coarseWidth = 10 * time.Millisecond
+)
+
+var (
+	mtx     sync.Mutex
+	histMap map[string]*Histogram
+)
+
+func init() {
+	histMap = map[string]*Histogram{}
+}
+
+type Histogram struct {
+	Buckets map[int]int64 `json:"buckets"`
+	Count   int64         `json:"count"`
+	Sum     time.Duration `json:"sum"`
+	Max     time.Duration `json:"max"`
+}
+
+type Summary struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{Buckets: map[int]int64{}}
+}
+
+func bucketIndex(d time.Duration) int {
+	if d < 0 {
+		d = 0
+	}
+	if d < fineLimit {
+		return int(d / fineWidth)
+	}
+	return int(fineLimit/fineWidth) + int((d-fineLimit)/coarseWidth)
+}
+
+// バケットの上限値
+func bucketUpper(i int) time.Duration {
+	n := int(fineLimit / fineWidth)
+	if i < n {
+		return time.Duration(i+1) * fineWidth
+	}
+	return fineLimit + time.Duration(i-n+1)*coarseWidth
+}
+
+func (h *Histogram) Add(d time.Duration) {
+	h.Buckets[bucketIndex(d)]++
+	h.Count++
+	h.Sum += d
+	if h.Max < d {
+		h.Max = d
+	}
+}
+
+func (h *Histogram) Merge(o *Histogram) {
+	for i, n := range o.Buckets {
+		h.Buckets[i] += n
+	}
+	h.Count += o.Count
+	h.Sum += o.Sum
+	if h.Max < o.Max {
+		h.Max = o.Max
+	}
+}
+
+// 以前のスナップショット o との差分にする
+// Max は差分からは分からないので残ったバケットの上限値にする
+func (h *Histogram) Sub(o *Histogram) {
+	h.Max = 0
+	for i, n := range h.Buckets {
+		n -= o.Buckets[i]
+		if n <= 0 {
+			delete(h.Buckets, i)
+			continue
+		}
+		h.Buckets[i] = n
+		if d := bucketUpper(i); h.Max < d {
+			h.Max = d
+		}
+	}
+	h.Count -= o.Count
+	h.Sum -= o.Sum
+}
+
+func (h *Histogram) Clone() *Histogram {
+	c := NewHistogram()
+	c.Merge(h)
+	return c
+}
+
+// q (0.0 - 1.0) 分位点の値を返す
+// バケットの上限値を返すので最大で 10ms の誤差がある
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	idx := make([]int, 0, len(h.Buckets))
+	for i := range h.Buckets {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+
+	rank := int64(q*float64(h.Count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var sum int64
+	for _, i := range idx {
+		sum += h.Buckets[i]
+		if rank <= sum {
+			d := bucketUpper(i)
+			if h.Max < d {
+				d = h.Max
+			}
+			return d
+		}
+	}
+	return h.Max
+}
+
+func (h *Histogram) Summary() *Summary {
+	s := &Summary{Count: h.Count}
+	if h.Count == 0 {
+		return s
+	}
+	s.Mean = toMillisecond(h.Sum / time.Duration(h.Count))
+	s.P50 = toMillisecond(h.Quantile(0.50))
+	s.P90 = toMillisecond(h.Quantile(0.90))
+	s.P99 = toMillisecond(h.Quantile(0.99))
+	s.Max = toMillisecond(h.Max)
+	return s
+}
+
+func toMillisecond(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func Record(key string, d time.Duration) {
+	mtx.Lock()
+	h, ok := histMap[key]
+	if !ok {
+		h = NewHistogram()
+		histMap[key] = h
+	}
+	h.Add(d)
+	mtx.Unlock()
+}
+
+func GetMap() map[string]*Histogram {
+	m := map[string]*Histogram{}
+	mtx.Lock()
+	for k, h := range histMap {
+		m[k] = h.Clone()
+	}
+	mtx.Unlock()
+	return m
+}
+
+// 分散実行でエージェントから集めたヒストグラムを足す
+func MergeMap(m map[string]*Histogram) {
+	mtx.Lock()
+	for k, o := range m {
+		h, ok := histMap[k]
+		if !ok {
+			h = NewHistogram()
+			histMap[k] = h
+		}
+		h.Merge(o)
+	}
+	mtx.Unlock()
+}
+
+func Reset() {
+	mtx.Lock()
+	histMap = map[string]*Histogram{}
+	mtx.Unlock()
+}
+
+// 全てのキーをまとめたヒストグラム
+func GetTotal() *Histogram {
+	t := NewHistogram()
+	mtx.Lock()
+	for _, h := range histMap {
+		t.Merge(h)
+	}
+	mtx.Unlock()
+	return t
+}
+
+func GetSummaries() map[string]*Summary {
+	m := map[string]*Summary{}
+	for k, h := range GetMap() {
+		m[k] = h.Summary()
+	}
+	return m
+}
@@ -0,0 +1,52 @@
+package latency
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuantile(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		values []time.Duration
+		q      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single", []time.Duration{5 * ms}, 0.99, 5 * ms},
+		{"median", []time.Duration{1 * ms, 2 * ms, 3 * ms, 4 * ms}, 0.5, 3 * ms},
+		{"p0 is first bucket", []time.Duration{1 * ms, 10 * ms}, 0, 2 * ms},
+		{"fine bucket upper", []time.Duration{1500 * time.Microsecond, 9 * ms}, 0.1, 2 * ms},
+		{"coarse bucket upper", []time.Duration{1 * ms, 1205 * ms, 1500 * ms}, 0.6, 1210 * ms},
+		{"clamped to max", []time.Duration{1 * ms, 1201 * ms}, 1, 1201 * ms},
+	}
+
+	for _, tt := range tests {
+		h := NewHistogram()
+		for _, v := range tt.values {
+			h.Add(v)
+		}
+		if got := h.Quantile(tt.q); got != tt.want {
+			t.Errorf("%s: Quantile(%v) = %v, want %v", tt.name, tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestSub(t *testing.T) {
+	ms := time.Millisecond
+	before := NewHistogram()
+	before.Add(1 * ms)
+	before.Add(500 * ms)
+
+	h := before.Clone()
+	h.Add(3 * ms)
+	h.Sub(before)
+
+	if h.Count != 1 || h.Sum != 3*ms {
+		t.Fatalf("count=%d sum=%v, want 1 3ms", h.Count, h.Sum)
+	}
+	if h.Max != 4*ms {
+		t.Errorf("Max = %v, want bucket upper 4ms", h.Max)
+	}
+}
@@ -0,0 +1,131 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// PlayWithResult の結果
+type PlayResult struct {
+	StatusCode int
+	Header     http.Header
+	// Location ヘッダの値 (リダイレクトでなければ空)
+	Location string
+	// CheckAction の KeepBody か Captures を指定した場合のみ入る
+	Body    []byte
+	Latency time.Duration
+	// CheckAction の Captures で取り出した値
+	Captures map[string]string
+	// ctx が終了していてリクエストを送らなかった
+	Skipped bool
+	// 鮮度のあるキャッシュがあったのでリクエストを送らなかった
+	Cached bool
+
+	doc *goquery.Document
+}
+
+// Location ヘッダのパス部分
+func (r *PlayResult) LocationPath() string {
+	u, err := url.Parse(r.Location)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// Body を HTML としてパースする。2回目以降はパースした結果を返す
+func (r *PlayResult) Document() (*goquery.Document, error) {
+	if r.doc != nil {
+		return r.doc, nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, fatalErrorf("ページのHTMLがパースできませんでした")
+	}
+	r.doc = doc
+	return doc, nil
+}
+
+func (r *PlayResult) Capture(name string) string {
+	return r.Captures[name]
+}
+
+type CaptureSource string
+
+const (
+	CaptureLocation CaptureSource = "location" // Location ヘッダのパス
+	CaptureHeader   CaptureSource = "header"   // Key で指定したレスポンスヘッダ
+	CaptureCookie   CaptureSource = "cookie"   // Key で指定した Set-Cookie の値
+	CaptureSelector CaptureSource = "selector" // Selector で選択した最初の要素の Attr (空ならテキスト)
+	CaptureBody     CaptureSource = "body"     // ボディ全体
+)
+
+// レスポンスから値を取り出す方法
+// Regexp を指定した場合は最初のグループ (グループがなければマッチした全体) を取り出す
+// 値が取り出せなければエラーにする (Optional の場合は空文字列)
+type Capture struct {
+	Name     string
+	From     CaptureSource
+	Key      string
+	Selector string
+	Attr     string
+	Regexp   *regexp.Regexp
+	Optional bool
+}
+
+func (cp *Capture) extract(res *http.Response, r *PlayResult) (string, error) {
+	var v string
+	switch cp.From {
+	case CaptureLocation:
+		v = r.LocationPath()
+		if v == "" && !cp.Optional {
+			return "", categoryErrorf(ErrorRedirect, "リダイレクトURLが適切に設定されていません")
+		}
+	case CaptureHeader:
+		v = res.Header.Get(cp.Key)
+	case CaptureCookie:
+		for _, ck := range res.Cookies() {
+			if ck.Name == cp.Key {
+				v = ck.Value
+			}
+		}
+	case CaptureSelector:
+		doc, err := r.Document()
+		if err != nil {
+			return "", err
+		}
+		sel := doc.Find(cp.Selector).First()
+		if cp.Attr != "" {
+			v, _ = sel.Attr(cp.Attr)
+		} else {
+			v = trim(sel.Text())
+		}
+	case CaptureBody:
+		v = string(r.Body)
+	default:
+		return "", fmt.Errorf("unsupported capture source %q", cp.From)
+	}
+
+	if cp.Regexp != nil {
+		m := cp.Regexp.FindStringSubmatch(v)
+		switch {
+		case m == nil:
+			v = ""
+		case len(m) > 1:
+			v = m[1]
+		default:
+			v = m[0]
+		}
+	}
+
+	if v == "" && !cp.Optional {
+		return "", fatalErrorf("%s が取得できませんでした", cp.Name)
+	}
+	return v, nil
+}
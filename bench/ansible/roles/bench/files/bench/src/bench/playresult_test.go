@@ -0,0 +1,56 @@
+package bench
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestCaptureExtract(t *testing.T) {
+	res := &http.Response{Header: http.Header{
+		"X-Request-Id": {"abc-123"},
+		"Set-Cookie":   {"session=s3cr3t; Path=/", "theme=dark"},
+	}}
+	r := &PlayResult{
+		Location: "http://example.com/bulletins/view/12?from=post",
+		Body:     []byte(`<html><body><h1> 社報 </h1><a class="edit" href="/bulletins/12/edit">編集</a></body></html>`),
+	}
+	empty := &PlayResult{}
+
+	tests := []struct {
+		name    string
+		cp      *Capture
+		r       *PlayResult
+		want    string
+		wantErr bool
+	}{
+		{"location", &Capture{Name: "loc", From: CaptureLocation}, r, "/bulletins/view/12", false},
+		{"location regexp", &Capture{Name: "id", From: CaptureLocation, Regexp: regexp.MustCompile(`/view/(\d+)`)}, r, "12", false},
+		{"no location", &Capture{Name: "loc", From: CaptureLocation}, empty, "", true},
+		{"optional location", &Capture{Name: "loc", From: CaptureLocation, Optional: true}, empty, "", false},
+		{"header", &Capture{Name: "rid", From: CaptureHeader, Key: "X-Request-Id"}, r, "abc-123", false},
+		{"cookie", &Capture{Name: "sess", From: CaptureCookie, Key: "session"}, r, "s3cr3t", false},
+		{"missing cookie", &Capture{Name: "sess", From: CaptureCookie, Key: "none"}, r, "", true},
+		{"selector text", &Capture{Name: "title", From: CaptureSelector, Selector: "h1"}, r, "社報", false},
+		{"selector attr", &Capture{Name: "edit", From: CaptureSelector, Selector: "a.edit", Attr: "href"}, r, "/bulletins/12/edit", false},
+		{"regexp without group", &Capture{Name: "n", From: CaptureSelector, Selector: "a.edit", Attr: "href", Regexp: regexp.MustCompile(`\d+`)}, r, "12", false},
+		{"regexp no match", &Capture{Name: "n", From: CaptureBody, Regexp: regexp.MustCompile(`token=(\w+)`)}, r, "", true},
+		{"unsupported", &Capture{Name: "x", From: "query"}, r, "", true},
+	}
+	for _, tt := range tests {
+		got, err := tt.cp.extract(res, tt.r)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: extract = %q, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: extract = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package bench
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ユーザの選択、ページや社報の ID、投稿するタイトルなどに使う乱数
+// SetSeed で同じ seed を指定すると同じ乱数列になる
+//
+// 負荷走行の goroutine は WithWorkerRand で seed から作った自分専用の乱数を ctx に持ち、
+// シナリオの中では RandFrom(ctx) から引く。他の goroutine の進み具合に左右されないので、
+// 各 goroutine が実行するシナリオの順番と投稿する内容は seed ごとに同じになる
+// (どのユーザが空いているかはタイミングで変わるので、PopRandomUser で選ばれるユーザまでは再現されない)
+
+// goroutine ごとの seed が重ならないように、番号に掛ける値 (2^64 / 黄金比)
+const workerSeedStep = -0x61c8864680b583eb
+
+type Rand struct {
+	mtx sync.Mutex
+	src *rand.Rand
+}
+
+func NewRand(seed int64) *Rand {
+	return &Rand{src: rand.New(rand.NewSource(seed))}
+}
+
+func (r *Rand) Intn(n int) int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.src.Intn(n)
+}
+
+func (r *Rand) Perm(n int) []int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.src.Perm(n)
+}
+
+func (r *Rand) AlphabetString(n int) string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	b := make([]rune, n)
+	for i := range b {
+		b[i] = alphabet[r.src.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+var (
+	randMtx  sync.Mutex
+	randSeed = time.Now().UnixNano()
+	randSrc  = NewRand(randSeed)
+)
+
+type randKey struct{}
+
+func SetSeed(seed int64) {
+	randMtx.Lock()
+	defer randMtx.Unlock()
+
+	randSeed = seed
+	randSrc = NewRand(seed)
+}
+
+func GetSeed() int64 {
+	randMtx.Lock()
+	defer randMtx.Unlock()
+
+	return randSeed
+}
+
+func globalRand() *Rand {
+	randMtx.Lock()
+	defer randMtx.Unlock()
+
+	return randSrc
+}
+
+// i 番目の goroutine 用の seed
+func WorkerSeed(i int) int64 {
+	return GetSeed() + int64(i+1)*workerSeedStep
+}
+
+// i 番目の goroutine 用の乱数を持たせた ctx を返す
+func WithWorkerRand(ctx context.Context, i int) context.Context {
+	return context.WithValue(ctx, randKey{}, NewRand(WorkerSeed(i)))
+}
+
+// ctx の乱数を返す。WithWorkerRand していなければ全体で共有する乱数を返す
+func RandFrom(ctx context.Context) *Rand {
+	if r, ok := ctx.Value(randKey{}).(*Rand); ok {
+		return r
+	}
+	return globalRand()
+}
+
+// 全体で共有する乱数。空いているユーザの選択 (PopRandomUser) に使い、シナリオの中では RandFrom(ctx) を使う
+func RandIntn(n int) int {
+	return globalRand().Intn(n)
+}
@@ -0,0 +1,52 @@
+package bench
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func drawSequence(ctx context.Context) []int {
+	r := RandFrom(ctx)
+	seq := make([]int, 20)
+	for i := range seq {
+		seq[i] = r.Intn(1000)
+	}
+	return seq
+}
+
+func TestWorkerRandReproducible(t *testing.T) {
+	SetSeed(0)
+	a := drawSequence(WithWorkerRand(context.Background(), 3))
+	// 他の goroutine が引いても影響しない
+	drawSequence(WithWorkerRand(context.Background(), 4))
+	RandIntn(10)
+	b := drawSequence(WithWorkerRand(context.Background(), 3))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed and worker gave different sequences: %v %v", a, b)
+	}
+
+	c := drawSequence(WithWorkerRand(context.Background(), 4))
+	if reflect.DeepEqual(a, c) {
+		t.Errorf("different workers gave the same sequence: %v", a)
+	}
+
+	SetSeed(1)
+	d := drawSequence(WithWorkerRand(context.Background(), 3))
+	if reflect.DeepEqual(a, d) {
+		t.Errorf("different seeds gave the same sequence: %v", a)
+	}
+}
+
+func TestRandFromFallsBackToGlobal(t *testing.T) {
+	SetSeed(42)
+	a := drawSequence(context.Background())
+	SetSeed(42)
+	b := []int{}
+	for i := 0; i < 20; i++ {
+		b = append(b, RandIntn(1000))
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("RandFrom without worker rand should use the global source: %v %v", a, b)
+	}
+}
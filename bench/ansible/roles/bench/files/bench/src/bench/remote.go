@@ -0,0 +1,74 @@
+package bench
+
+import (
+	"strings"
+	"time"
+)
+
+// 分散実行でエージェントからコーディネータに送るエラー
+type RemoteError struct {
+	Time        time.Time     `json:"time"`
+	Category    ErrorCategory `json:"category"`
+	Method      string        `json:"method"`
+	Path        string        `json:"path"`
+	Query       string        `json:"query"`
+	Description string        `json:"description"`
+	Message     string        `json:"message"`
+}
+
+func ExportCheckerErrors() []*RemoteError {
+	checkerMtx.Lock()
+	defer checkerMtx.Unlock()
+
+	errs := []*RemoteError{}
+	for _, e := range checkerErrors {
+		errs = append(errs, &RemoteError{
+			Time:        e.t,
+			Category:    e.category,
+			Method:      e.method,
+			Path:        e.path,
+			Query:       e.query,
+			Description: e.description,
+			Message:     e.err.Error(),
+		})
+	}
+	return errs
+}
+
+// エージェントでは負荷走行の終了時にエラーの収集をやめているので、ここでは GuardCheckerError に関係なく追加する
+func ImportCheckerErrors(node string, errs []*RemoteError) {
+	checkerMtx.Lock()
+	defer checkerMtx.Unlock()
+
+	for _, r := range errs {
+		var err error
+		switch r.Category {
+		case ErrorFatal:
+			err = &fatalError{strings.TrimPrefix(r.Message, "[Fatal]")}
+		case ErrorTimeout:
+			err = RequestTimeoutError
+		default:
+			err = &categorizedError{r.Category, r.Message}
+		}
+		checkerErrors = append(checkerErrors, &CheckerError{
+			t:           r.Time,
+			err:         err,
+			method:      r.Method,
+			path:        r.Path,
+			query:       r.Query,
+			category:    r.Category,
+			description: r.Description,
+			node:        node,
+		})
+	}
+}
+
+// エージェントで次の負荷走行を始める前に呼ぶ
+func ResetCheckerErrors() {
+	checkerMtx.Lock()
+	defer checkerMtx.Unlock()
+
+	checkerErrors = nil
+	checkerErrorGuard = false
+	checkerLastSlowPath = ""
+}
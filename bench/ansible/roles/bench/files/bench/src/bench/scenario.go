@@ -5,9 +5,9 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -24,26 +24,6 @@ var (
 	loginReg = regexp.MustCompile(`^/login$`)
 )
 
-func getCsrfToken(checker *Checker, ctx context.Context, url string) (csrf_token string, err error) {
-	err = checker.Play(ctx, &CheckAction{
-		Method:             "GET",
-		Path:               url,
-		ExpectedStatusCode: 200,
-		CheckFunc: checkHTML(func(res *http.Response, doc *goquery.Document) error {
-			if url == "/login" {
-				csrf_token, _ = doc.Find("body > form > input").First().Attr("value")
-			} else if url == "/bulletins/add" {
-				csrf_token, _ = doc.Find("body > main > div.container > div.py-3 > form > input").First().Attr("value")
-			} else {
-				csrf_token, _ = doc.Find("body > div.container > div.py-3 > form > input").First().Attr("value")
-			}
-			return nil
-		}),
-		Description: "csrf_tokenを取得",
-	})
-	return csrf_token, err
-}
-
 func checkHTML(f func(*http.Response, *goquery.Document) error) func(*http.Response, *bytes.Buffer) error {
 	return func(res *http.Response, body *bytes.Buffer) error {
 		doc, err := goquery.NewDocumentFromReader(body)
@@ -54,7 +34,7 @@ func checkHTML(f func(*http.Response, *goquery.Document) error) func(*http.Respo
 	}
 }
 
-func genPostImageBody(fileName string, postBodyNames []string, postBodyValues map[string]string) (*bytes.Buffer, string, error) {
+func genPostImageBody(ctx context.Context, fileName string, postBodyNames []string, postBodyValues map[string]string) (*bytes.Buffer, string, error) {
 	body := new(bytes.Buffer)
 	writer := multipart.NewWriter(body)
 
@@ -65,7 +45,7 @@ func genPostImageBody(fileName string, postBodyNames []string, postBodyValues ma
 		writer.WriteField(postBodyNames[i], postBodyValues[postBodyNames[i]])
 	}
 
-	imageNum := rand.Perm(len(UploadFileImages) - 1)[0]
+	imageNum := RandFrom(ctx).Intn(len(UploadFileImages))
 	image := UploadFileImages[imageNum]
 
 	uploadFile := filepath.Join(DataPath, image.Path)
@@ -86,18 +66,52 @@ func genPostImageBody(fileName string, postBodyNames []string, postBodyValues ma
 	return body, writer.FormDataContentType(), err
 }
 
+// genPostImageBody の画像を指定する版
+// アップロードした画像を後で取得して確認するので、データの内容が UploadFileImages と一致することも確認する
+func genPostImageBodyFrom(image *UploadFileImage, fileName string, postBodyNames []string, postBodyValues map[string]string) (*bytes.Buffer, string, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for i := 0; i < len(postBodyNames); i++ {
+		writer.WriteField(postBodyNames[i], postBodyValues[postBodyNames[i]])
+	}
+
+	fileWriter, err := writer.CreateFormFile("icon", fileName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	readFile, err := os.Open(filepath.Join(DataPath, image.Path))
+	if err != nil {
+		return nil, "", err
+	}
+	defer readFile.Close()
+
+	hasher := md5.New()
+	n, err := io.Copy(io.MultiWriter(fileWriter, hasher), readFile)
+	if err != nil {
+		return nil, "", err
+	}
+	if n != image.Size || hex.EncodeToString(hasher.Sum(nil)) != image.Hash {
+		return nil, "", fmt.Errorf("%s の内容が正しくありません (主催者に連絡してください)", image.Path)
+	}
+	writer.Close()
+
+	return body, writer.FormDataContentType(), nil
+}
+
 func checkRedirectStatusCode(res *http.Response, body *bytes.Buffer) error {
 	if res.StatusCode == 302 || res.StatusCode == 303 {
 		return nil
 	}
-	return fmt.Errorf("期待していないステータスコード %d Expected 302 or 303", res.StatusCode)
+	return categoryErrorf(ErrorStatus, "期待していないステータスコード %d Expected 302 or 303", res.StatusCode)
 }
 
 func checkRedirectStatusCodeError(res *http.Response, body *bytes.Buffer) error {
 	if res.StatusCode == 403 {
 		return nil
 	}
-	return fmt.Errorf("期待していないステータスコード %d Expected 403", res.StatusCode)
+	return categoryErrorf(ErrorStatus, "期待していないステータスコード %d Expected 403", res.StatusCode)
 }
 
 func PreAddUser(ctx context.Context, state *State) error {
@@ -118,9 +132,9 @@ func PreAddUser(ctx context.Context, state *State) error {
 		return err
 	}
 
-	fileName := RandomAlphabetString(20) + ".png"
-	newUser := RandomAlphabetString(4)
-	newPass := RandomAlphabetString(8)
+	fileName := RandFrom(ctx).AlphabetString(20) + ".png"
+	newUser := RandFrom(ctx).AlphabetString(4)
+	newPass := RandFrom(ctx).AlphabetString(8)
 	postBodyNames := []string{"username", "password", "password_confirm", "nickname", "csrf_token"}
 	postBodyValues := make(map[string]string)
 	postBodyValues["username"] = newUser
@@ -129,9 +143,8 @@ func PreAddUser(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = newUser + "-san"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err := genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err := genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 
-	rand.Seed(time.Now().UnixNano())
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/users/add",
@@ -182,8 +195,7 @@ func PreAddUser(ctx context.Context, state *State) error {
 		Password: newPass,
 	}
 
-	state.userMap[addUser.Name] = addUser
-	state.users = append(state.users, addUser)
+	state.PushUser(addUser)
 
 	return nil
 }
@@ -1276,7 +1288,7 @@ func CheckValidation(ctx context.Context, state *State) error {
 	if err != nil {
 		return err
 	}
-	fileName := RandomAlphabetString(10) + ".png"
+	fileName := RandFrom(ctx).AlphabetString(10) + ".png"
 	postBodyNames := []string{"username", "password", "password_confirm", "nickname", "csrf_token"}
 	postBodyValues := make(map[string]string)
 	postBodyValues["username"] = username
@@ -1285,7 +1297,7 @@ func CheckValidation(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = "testest"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err := genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err := genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:             "POST",
 		Path:               "/users/add",
@@ -1309,7 +1321,7 @@ func CheckValidation(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = nickname
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err = genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err = genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:             "POST",
 		Path:               "/users/add",
@@ -1332,7 +1344,7 @@ func CheckValidation(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = "testest"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err = genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err = genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:             "POST",
 		Path:               "/users/add",
@@ -2013,8 +2025,8 @@ func CheckLogin(ctx context.Context, state *State) error {
 		Path:               "/login",
 		ExpectedStatusCode: 403,
 		PostData: map[string]string{
-			"name":       RandomAlphabetString(30),
-			"password":   RandomAlphabetString(30),
+			"name":       RandFrom(ctx).AlphabetString(30),
+			"password":   RandFrom(ctx).AlphabetString(30),
 			"csrf_token": csrf_token,
 		},
 		Description: "存在しないユーザでログインできないこと",
@@ -2041,7 +2053,7 @@ func CheckAddUser(ctx context.Context, state *State) error {
 		return err
 	}
 
-	fileName := RandomAlphabetString(10) + ".png"
+	fileName := RandFrom(ctx).AlphabetString(10) + ".png"
 	postBodyNames := []string{"username", "password", "password_confirm", "nickname", "csrf_token"}
 	postBodyValues := make(map[string]string)
 	postBodyValues["username"] = user.Name
@@ -2050,7 +2062,7 @@ func CheckAddUser(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = user.Name + "-san"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err := genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err := genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:             "POST",
 		Path:               "/users/add",
@@ -2064,8 +2076,8 @@ func CheckAddUser(ctx context.Context, state *State) error {
 	}
 
 	// 登録するユーザ名とパスワードを生成(ランダム文字列)
-	newUser := RandomAlphabetString(10)
-	newPass := RandomAlphabetString(10)
+	newUser := RandFrom(ctx).AlphabetString(10)
+	newPass := RandFrom(ctx).AlphabetString(10)
 
 	// 登録前のユーザとパスワードでログインできないことを確認
 	url = "/login"
@@ -2098,7 +2110,7 @@ func CheckAddUser(ctx context.Context, state *State) error {
 		return err
 	}
 
-	fileName = RandomAlphabetString(10) + ".png"
+	fileName = RandFrom(ctx).AlphabetString(10) + ".png"
 	postBodyNames = []string{"username", "password", "password_confirm", "nickname", "csrf_token"}
 	postBodyValues = make(map[string]string)
 	postBodyValues["username"] = newUser
@@ -2107,7 +2119,7 @@ func CheckAddUser(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = newUser + "-san"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err = genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err = genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/users/add",
@@ -2513,13 +2525,18 @@ func CheckImage(ctx context.Context, state *State) error {
 		return err
 	}
 
-	fileName := RandomAlphabetString(20) + ".png"
+	fileName := RandFrom(ctx).AlphabetString(20) + ".png"
 	postBodyNames := []string{"username", "nickname", "csrf_token"}
 	postBodyValues := make(map[string]string)
 	postBodyValues["username"] = ""
 	postBodyValues["nickname"] = ""
 	postBodyValues["csrf_token"] = csrf_token
-	body, ctype, err := genPostImageBody(fileName, postBodyNames, postBodyValues)
+	// アップロードしたものと同じ内容が返ってくるか確認するので、画像を先に選んでおく
+	image := UploadFileImages[RandFrom(ctx).Intn(len(UploadFileImages))]
+	body, ctype, err := genPostImageBodyFrom(image, fileName, postBodyNames, postBodyValues)
+	if err != nil {
+		return err
+	}
 
 	err = checker.Play(ctx, &CheckAction{
 		//DisableSlowChecking: true,
@@ -2588,15 +2605,9 @@ func CheckImage(ctx context.Context, state *State) error {
 			if res.StatusCode == http.StatusOK {
 				counter.IncKey("staticfile-200")
 			} else {
-				return fmt.Errorf("期待していないステータスコード %d", res.StatusCode)
+				return categoryErrorf(ErrorStatus, "期待していないステータスコード %d", res.StatusCode)
 			}
-
-			hasher := md5.New()
-			_, err := io.Copy(hasher, body)
-			if err != nil {
-				return fatalErrorf("レスポンスボディの取得に失敗 %v", err)
-			}
-			return nil
+			return verifyStaticFileBody(res.Request.URL.Path, body, image.Size, image.Hash)
 		},
 	})
 	if err != nil {
@@ -2617,6 +2628,39 @@ func CheckImage(ctx context.Context, state *State) error {
 	return nil
 }
 
+// 静的ファイルの内容が変更されていないことをサイズと MD5 で確認する (レギュレーションで変更を禁止している)
+func verifyStaticFileBody(path string, body *bytes.Buffer, size int64, hash string) error {
+	hasher := md5.New()
+	n, err := io.Copy(hasher, body)
+	if err != nil {
+		return fatalErrorf("レスポンスボディの取得に失敗 %v", err)
+	}
+	if n != size {
+		return fatalErrorf("静的ファイルのサイズが正しくありません %s (expected %d, got %d)", path, size, n)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		return fatalErrorf("静的ファイルの内容が正しくありません %s", path)
+	}
+	return nil
+}
+
+// 静的ファイルを取得する CheckAction の CheckFunc
+// 304 はボディがないので内容は確認しない
+func checkStaticFile(path string, size int64, hash string) func(*http.Response, *bytes.Buffer) error {
+	return func(res *http.Response, body *bytes.Buffer) error {
+		if res.StatusCode == http.StatusOK {
+			counter.IncKey("staticfile-200")
+			return verifyStaticFileBody(path, body, size, hash)
+		} else if res.StatusCode == http.StatusNotModified {
+			counter.IncKey("staticfile-304")
+			// -score-config の endpoint_weights で使うパスごとの件数
+			counter.IncKey("staticfile-304|" + path)
+			return nil
+		}
+		return categoryErrorf(ErrorStatus, "期待していないステータスコード %d", res.StatusCode)
+	}
+}
+
 func CheckStaticFiles(ctx context.Context, state *State) error {
 	user, checker, push := state.PopRandomUser()
 	if user == nil {
@@ -2632,30 +2676,14 @@ func CheckStaticFiles(ctx context.Context, state *State) error {
 			Description:          "静的ファイルが取得できること",
 			EnableCache:          true,
 			SkipIfCacheAvailable: true,
-			CheckFunc: func(res *http.Response, body *bytes.Buffer) error {
-				if res.StatusCode == http.StatusOK {
-					counter.IncKey("staticfile-200")
-				} else if res.StatusCode == http.StatusNotModified {
-					counter.IncKey("staticfile-304")
-				} else {
-					return fmt.Errorf("期待していないステータスコード %d", res.StatusCode)
-				}
-
-				hasher := md5.New()
-				_, err := io.Copy(hasher, body)
-				if err != nil {
-					return fatalErrorf("レスポンスボディの取得に失敗 %v", err)
-				}
-
-				return nil
-			},
+			CheckFunc:            checkStaticFile(sf.Path, sf.Size, sf.Hash),
 		})
 		if err != nil {
 			return err
 		}
 	}
 
-	imageNum := rand.Perm(len(StaticFileImages) - 1)[0]
+	imageNum := RandFrom(ctx).Intn(len(StaticFileImages))
 	image := StaticFileImages[imageNum]
 	err := checker.Play(ctx, &CheckAction{
 		Method:               "GET",
@@ -2663,22 +2691,7 @@ func CheckStaticFiles(ctx context.Context, state *State) error {
 		Description:          "静的ファイルが取得できること",
 		EnableCache:          true,
 		SkipIfCacheAvailable: true,
-		CheckFunc: func(res *http.Response, body *bytes.Buffer) error {
-			if res.StatusCode == http.StatusOK {
-				counter.IncKey("staticfile-200")
-			} else if res.StatusCode == http.StatusNotModified {
-				counter.IncKey("staticfile-304")
-			} else {
-				return fmt.Errorf("期待していないステータスコード %d", res.StatusCode)
-			}
-
-			hasher := md5.New()
-			_, err := io.Copy(hasher, body)
-			if err != nil {
-				return fatalErrorf("レスポンスボディの取得に失敗 %v", err)
-			}
-			return nil
-		},
+		CheckFunc:            checkStaticFile(image.Path, image.Size, image.Hash),
 	})
 	if err != nil {
 		return err
@@ -2725,9 +2738,9 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 		return err
 	}
 
-	title := RandomAlphabetString(16)
-	body := RandomAlphabetString(256)
-	redirect_url, err := checker.PlayReturnRedirect(ctx, &CheckAction{
+	title := RandFrom(ctx).AlphabetString(16)
+	body := RandFrom(ctx).AlphabetString(256)
+	result, err := checker.PlayWithResult(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/bulletins/add",
 		CheckFunc:   checkRedirectStatusCode,
@@ -2737,14 +2750,15 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 			"body":       body,
 			"csrf_token": csrf_token,
 		},
+		Captures: []*Capture{
+			{Name: "view_path", From: CaptureLocation},
+		},
 	})
-	if err != nil {
+	if err != nil || result.Skipped {
 		return err
 	}
 
-	slice := strings.Split(redirect_url, "/")
-	viewURL := strings.Join(slice[3:], "/")
-	viewURL = "/" + viewURL
+	viewURL := result.Capture("view_path")
 
 	// 新規投稿した社報が閲覧できること
 	view_editURL := ""
@@ -2837,8 +2851,8 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 		return err
 	}
 
-	newTitle := RandomAlphabetString(24)
-	newBody := RandomAlphabetString(512)
+	newTitle := RandFrom(ctx).AlphabetString(24)
+	newBody := RandFrom(ctx).AlphabetString(512)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        view_editURL,
@@ -2881,7 +2895,7 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 	}
 
 	bulletin_id := strings.Split(view_editURL, "/")[3]
-	comText1 := RandomAlphabetString(10)
+	comText1 := RandFrom(ctx).AlphabetString(10)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/bulletins/add_comment",
@@ -2896,7 +2910,7 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 	if err != nil {
 		return err
 	}
-	comText2 := RandomAlphabetString(20)
+	comText2 := RandFrom(ctx).AlphabetString(20)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/bulletins/add_comment",
@@ -2911,7 +2925,7 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 	if err != nil {
 		return err
 	}
-	comText3 := RandomAlphabetString(30)
+	comText3 := RandFrom(ctx).AlphabetString(30)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/bulletins/add_comment",
@@ -3003,7 +3017,7 @@ func LoadPostOperation(ctx context.Context, state *State) error {
 		return err
 	}
 
-	newComText := RandomAlphabetString(40)
+	newComText := RandFrom(ctx).AlphabetString(40)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        comment_editURL,
@@ -3118,9 +3132,9 @@ func LoadUserOperation(ctx context.Context, state *State) error {
 	if err != nil {
 		return err
 	}
-	fileName := RandomAlphabetString(20) + ".png"
-	newUser := RandomAlphabetString(4)
-	newPass := RandomAlphabetString(8)
+	fileName := RandFrom(ctx).AlphabetString(20) + ".png"
+	newUser := RandFrom(ctx).AlphabetString(4)
+	newPass := RandFrom(ctx).AlphabetString(8)
 	postBodyNames := []string{"username", "password", "password_confirm", "nickname", "csrf_token"}
 	postBodyValues := make(map[string]string)
 	postBodyValues["username"] = newUser
@@ -3129,7 +3143,7 @@ func LoadUserOperation(ctx context.Context, state *State) error {
 	postBodyValues["nickname"] = newUser + "-san"
 	postBodyValues["csrf_token"] = csrf_token
 
-	body, ctype, err := genPostImageBody(fileName, postBodyNames, postBodyValues)
+	body, ctype, err := genPostImageBody(ctx, fileName, postBodyNames, postBodyValues)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/users/add",
@@ -3183,7 +3197,7 @@ func LoadUserOperation(ctx context.Context, state *State) error {
 	if err != nil {
 		return err
 	}
-	updatePass := RandomAlphabetString(16)
+	updatePass := RandFrom(ctx).AlphabetString(16)
 	err = checker.Play(ctx, &CheckAction{
 		Method:      "POST",
 		Path:        "/users/password",
@@ -3279,8 +3293,7 @@ func LoadReadOperation(ctx context.Context, state *State) error {
 	}
 
 	// 社報一覧が正常に表示されること
-	rand.Seed(time.Now().UnixNano())
-	page := rand.Perm(200)[0]
+	page := RandFrom(ctx).Intn(200)
 	page += 1
 	err = checker.Play(ctx, &CheckAction{
 		Method:             "GET",
@@ -3480,8 +3493,7 @@ func LoadReadOperation(ctx context.Context, state *State) error {
 	}
 
 	for i := 0; i < 20; i++ {
-		rand.Seed(time.Now().UnixNano())
-		id := rand.Perm(2562)[0]
+		id := RandFrom(ctx).Intn(2562)
 		id = id + 1
 		err = checker.Play(ctx, &CheckAction{
 			Method:             "GET",
@@ -3495,8 +3507,7 @@ func LoadReadOperation(ctx context.Context, state *State) error {
 	}
 
 	for i := 0; i < 5; i++ {
-		rand.Seed(time.Now().UnixNano())
-		page := rand.Perm(200)[0]
+		page := RandFrom(ctx).Intn(200)
 		page += 1
 		err = checker.Play(ctx, &CheckAction{
 			Method:             "GET",
@@ -3510,7 +3521,7 @@ func LoadReadOperation(ctx context.Context, state *State) error {
 
 	}
 
-	imageNum := rand.Perm(len(StaticFileImages) - 1)[0]
+	imageNum := RandFrom(ctx).Intn(len(StaticFileImages))
 	image := StaticFileImages[imageNum]
 	err = checker.Play(ctx, &CheckAction{
 		Method:               "GET",
@@ -3518,22 +3529,7 @@ func LoadReadOperation(ctx context.Context, state *State) error {
 		Description:          "静的ファイルが取得できること",
 		EnableCache:          true,
 		SkipIfCacheAvailable: true,
-		CheckFunc: func(res *http.Response, body *bytes.Buffer) error {
-			if res.StatusCode == http.StatusOK {
-				counter.IncKey("staticfile-200")
-			} else if res.StatusCode == http.StatusNotModified {
-				counter.IncKey("staticfile-304")
-			} else {
-				return fmt.Errorf("期待していないステータスコード %d", res.StatusCode)
-			}
-
-			hasher := md5.New()
-			_, err := io.Copy(hasher, body)
-			if err != nil {
-				return fatalErrorf("レスポンスボディの取得に失敗 %v", err)
-			}
-			return nil
-		},
+		CheckFunc:            checkStaticFile(image.Path, image.Size, image.Hash),
 	})
 	if err != nil {
 		return err
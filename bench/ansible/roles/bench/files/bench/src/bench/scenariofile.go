@@ -0,0 +1,386 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// シナリオ定義ファイル (JSON) の形式
+//
+//	{
+//	  "name": "read-bulletins",
+//	  "weight": 3,
+//	  "vars": {"title": "${random:16}"},
+//	  "steps": [
+//	    {"method": "POST", "path": "/login", "csrf_from": "/login", "redirect": true,
+//	     "form": {"name": "${user.name}", "password": "${user.password}"}},
+//	    {"method": "GET", "path": "/bulletins?page=${int:200}", "status": 200,
+//	     "assert": [{"selector": "td.table-contents-title", "count": 10, "message": "社報が10件表示されていません。"}]}
+//	  ]
+//	}
+//
+// 値の中の ${...} は実行時に展開する
+//   - ${user.name}, ${user.password}: シナリオを実行するユーザ
+//   - ${random:N}: N 文字のランダムな英数字
+//   - ${int:N}: 1 から N までのランダムな整数
+//   - ${csrf_token}: 直前の csrf_from で取得したトークン
+//   - それ以外: vars または capture で定義した変数
+type ScenarioFile struct {
+	Name   string            `json:"name"`
+	Weight int               `json:"weight"`
+	Vars   map[string]string `json:"vars"`
+	Steps  []*ScenarioStep   `json:"steps"`
+}
+
+type ScenarioStep struct {
+	Description string `json:"description"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Repeat      int    `json:"repeat"`
+
+	// POST するフォームの値と csrf_token を取得するページ
+	Form     map[string]string `json:"form"`
+	CsrfFrom string            `json:"csrf_from"`
+	// 指定した場合は multipart/form-data でアイコン画像をこのファイル名で送る
+	UploadFileName string `json:"upload_file_name"`
+
+	ExpectedStatus   int    `json:"status"`
+	ExpectRedirect   bool   `json:"redirect"`
+	ExpectedLocation string `json:"location"`
+
+	Assertions []*ScenarioAssertion `json:"assert"`
+	Captures   []*ScenarioCapture   `json:"capture"`
+
+	expectedLocation *regexp.Regexp
+}
+
+// selector で選択した要素に対する検証
+// いずれかの条件を満たさなければ fatal error とする
+type ScenarioAssertion struct {
+	Selector string `json:"selector"`
+	Attr     string `json:"attr"`
+	Equals   string `json:"equals"`
+	Contains string `json:"contains"`
+	Count    *int   `json:"count"`
+	MinCount int    `json:"min_count"`
+	Message  string `json:"message"`
+}
+
+// レスポンスから値を取り出して変数に保存する
+// from は "location" (リダイレクト先のパス), "selector", "header", "cookie" (key で名前を指定), "body"
+type ScenarioCapture struct {
+	Name     string `json:"name"`
+	From     string `json:"from"`
+	Key      string `json:"key"`
+	Selector string `json:"selector"`
+	Attr     string `json:"attr"`
+	Regexp   string `json:"regexp"`
+	Optional bool   `json:"optional"`
+
+	re *regexp.Regexp
+}
+
+var scenarioVarReg = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// path がディレクトリの場合は直下の *.json を全て読み込む
+func LoadScenarioFiles(path string) ([]*ScenarioFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	}
+
+	var scenarios []*ScenarioFile
+	for _, f := range files {
+		s, err := LoadScenarioFile(f)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, s)
+	}
+	return scenarios, nil
+}
+
+func LoadScenarioFile(path string) (*ScenarioFile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := new(ScenarioFile)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if s.Weight <= 0 {
+		s.Weight = 1
+	}
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *ScenarioFile) compile() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("steps is empty")
+	}
+
+	for i, step := range s.Steps {
+		step.Method = strings.ToUpper(step.Method)
+		if step.Method != "GET" && step.Method != "POST" {
+			return fmt.Errorf("steps[%d]: unsupported method %q", i, step.Method)
+		}
+		if step.Path == "" {
+			return fmt.Errorf("steps[%d]: path is empty", i)
+		}
+		if step.ExpectedLocation != "" {
+			re, err := regexp.Compile(step.ExpectedLocation)
+			if err != nil {
+				return fmt.Errorf("steps[%d]: location: %v", i, err)
+			}
+			step.expectedLocation = re
+		}
+		for j, a := range step.Assertions {
+			if a.Selector == "" {
+				return fmt.Errorf("steps[%d].assert[%d]: selector is empty", i, j)
+			}
+		}
+		for j, c := range step.Captures {
+			if c.Name == "" {
+				return fmt.Errorf("steps[%d].capture[%d]: name is empty", i, j)
+			}
+			switch CaptureSource(c.From) {
+			case CaptureLocation, CaptureBody:
+			case CaptureSelector:
+				if c.Selector == "" {
+					return fmt.Errorf("steps[%d].capture[%d]: selector is empty", i, j)
+				}
+			case CaptureHeader, CaptureCookie:
+				if c.Key == "" {
+					return fmt.Errorf("steps[%d].capture[%d]: key is empty", i, j)
+				}
+			default:
+				return fmt.Errorf("steps[%d].capture[%d]: unsupported from %q", i, j, c.From)
+			}
+			if c.Regexp != "" {
+				re, err := regexp.Compile(c.Regexp)
+				if err != nil {
+					return fmt.Errorf("steps[%d].capture[%d]: regexp: %v", i, j, err)
+				}
+				c.re = re
+			}
+		}
+	}
+	return nil
+}
+
+// 負荷走行用の関数として実行する
+func (s *ScenarioFile) Run(ctx context.Context, state *State) error {
+	user, checker, push := state.PopRandomUser()
+	if user == nil {
+		return nil
+	}
+	defer push()
+
+	vars := map[string]string{}
+	names := make([]string, 0, len(s.Vars))
+	for name := range s.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vars[name] = expandScenarioVars(ctx, s.Vars[name], user, vars)
+	}
+
+	for _, step := range s.Steps {
+		n := step.Repeat
+		if n <= 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			err := s.runStep(ctx, checker, user, step, vars)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *ScenarioFile) runStep(ctx context.Context, checker *Checker, user *AppUser, step *ScenarioStep, vars map[string]string) error {
+	if step.CsrfFrom != "" {
+		csrf_token, err := getCsrfToken(checker, ctx, expandScenarioVars(ctx, step.CsrfFrom, user, vars))
+		if err != nil {
+			return err
+		}
+		vars["csrf_token"] = csrf_token
+	}
+
+	description := step.Description
+	if description == "" {
+		description = fmt.Sprintf("%s: %s %s", s.Name, step.Method, step.Path)
+	}
+
+	a := &CheckAction{
+		Method:             step.Method,
+		Path:               expandScenarioVars(ctx, step.Path, user, vars),
+		ExpectedStatusCode: step.ExpectedStatus,
+		ExpectedLocation:   step.expectedLocation,
+		Description:        description,
+	}
+
+	if step.Method == "POST" {
+		form := map[string]string{}
+		for key, val := range step.Form {
+			form[key] = expandScenarioVars(ctx, val, user, vars)
+		}
+		if _, ok := form["csrf_token"]; !ok && step.CsrfFrom != "" {
+			form["csrf_token"] = vars["csrf_token"]
+		}
+
+		if step.UploadFileName != "" {
+			names := make([]string, 0, len(form))
+			for key := range form {
+				names = append(names, key)
+			}
+			sort.Strings(names)
+			body, ctype, err := genPostImageBody(ctx, expandScenarioVars(ctx, step.UploadFileName, user, vars), names, form)
+			if err != nil {
+				return err
+			}
+			a.PostBody = body
+			a.ContentType = ctype
+		} else {
+			a.PostData = form
+		}
+	}
+
+	a.CheckFunc = func(res *http.Response, body *bytes.Buffer) error {
+		if step.ExpectRedirect {
+			if err := checkRedirectStatusCode(res, body); err != nil {
+				return err
+			}
+		}
+
+		if len(step.Assertions) == 0 {
+			return nil
+		}
+
+		return checkHTML(func(res *http.Response, doc *goquery.Document) error {
+			for _, as := range step.Assertions {
+				if err := as.check(ctx, doc, user, vars); err != nil {
+					return err
+				}
+			}
+			return nil
+		})(res, body)
+	}
+
+	for _, c := range step.Captures {
+		a.Captures = append(a.Captures, &Capture{
+			Name:     c.Name,
+			From:     CaptureSource(c.From),
+			Key:      c.Key,
+			Selector: expandScenarioVars(ctx, c.Selector, user, vars),
+			Attr:     c.Attr,
+			Regexp:   c.re,
+			Optional: c.Optional,
+		})
+	}
+
+	result, err := checker.PlayWithResult(ctx, a)
+	if err != nil {
+		return err
+	}
+	for name, v := range result.Captures {
+		vars[name] = v
+	}
+	return nil
+}
+
+func (as *ScenarioAssertion) check(ctx context.Context, doc *goquery.Document, user *AppUser, vars map[string]string) error {
+	sel := doc.Find(expandScenarioVars(ctx, as.Selector, user, vars))
+
+	msg := as.Message
+	if msg == "" {
+		msg = fmt.Sprintf("%s が適切に表示されていません。", as.Selector)
+	}
+
+	if as.Count != nil && sel.Length() != *as.Count {
+		return fatalErrorf("%s", msg)
+	}
+	if sel.Length() < as.MinCount {
+		return fatalErrorf("%s", msg)
+	}
+
+	if as.Equals == "" && as.Contains == "" {
+		return nil
+	}
+
+	var v string
+	if as.Attr != "" {
+		v, _ = sel.First().Attr(as.Attr)
+	} else {
+		v = sel.Text()
+	}
+
+	if as.Equals != "" && v != expandScenarioVars(ctx, as.Equals, user, vars) {
+		return fatalErrorf("%s", msg)
+	}
+	if as.Contains != "" && !strings.Contains(v, expandScenarioVars(ctx, as.Contains, user, vars)) {
+		return fatalErrorf("%s", msg)
+	}
+	return nil
+}
+
+func expandScenarioVars(ctx context.Context, s string, user *AppUser, vars map[string]string) string {
+	return scenarioVarReg.ReplaceAllStringFunc(s, func(m string) string {
+		name := m[2 : len(m)-1]
+		switch {
+		case name == "user.name":
+			return user.Name
+		case name == "user.password":
+			return user.Password
+		case strings.HasPrefix(name, "random:"):
+			n, err := strconv.Atoi(name[len("random:"):])
+			if err != nil {
+				return m
+			}
+			return RandFrom(ctx).AlphabetString(n)
+		case strings.HasPrefix(name, "int:"):
+			n, err := strconv.Atoi(name[len("int:"):])
+			if err != nil || n <= 0 {
+				return m
+			}
+			return strconv.Itoa(RandFrom(ctx).Intn(n) + 1)
+		}
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
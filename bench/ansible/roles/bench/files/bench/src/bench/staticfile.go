@@ -1,208 +1,218 @@
+// Code generated by cmd/update. DO NOT EDIT.
+
 package bench
 
 type StaticFile struct {
 	Path string
+	Size int64
+	Hash string
 }
 
 type StaticFileImage struct {
 	Path string
+	Size int64
+	Hash string
 }
 
 type UploadFileImage struct {
 	Path string
+	Size int64
+	Hash string
 }
 
 var (
 	StaticFiles = []*StaticFile{
-		&StaticFile{"/static/css/main.css"},
-		&StaticFile{"/static/css/bootstrap.min.css"},
+		&StaticFile{"/static/css/bootstrap.min.css", 155760, "2da021a9d89e7817b3dd08f6aa18bb3a"},
+		&StaticFile{"/static/css/main.css", 3623, "947fc2cf66a6fb1c26f1dba95b1ff17b"},
+		&StaticFile{"/static/js/modal.js", 199, "61d458d9f7f95e271e8a0ac6af2e16e2"},
+		&StaticFile{"/static/js/star.js", 703, "f029f090b680391d0f5fb6f25e603b81"},
 	}
 
 	StaticFileImages = []*StaticFileImage{
-		&StaticFileImage{"/static/icons/abe.png"},
-		&StaticFileImage{"/static/icons/ando.png"},
-		&StaticFileImage{"/static/icons/aoki.png"},
-		&StaticFileImage{"/static/icons/arai.png"},
-		&StaticFileImage{"/static/icons/chiba.png"},
-		&StaticFileImage{"/static/icons/default-icon.png"},
-		&StaticFileImage{"/static/icons/endo.png"},
-		&StaticFileImage{"/static/icons/fujii.png"},
-		&StaticFileImage{"/static/icons/fujimoto.png"},
-		&StaticFileImage{"/static/icons/fujita.png"},
-		&StaticFileImage{"/static/icons/fujiwara.png"},
-		&StaticFileImage{"/static/icons/fukuda.png"},
-		&StaticFileImage{"/static/icons/goto.png"},
-		&StaticFileImage{"/static/icons/hamada.png"},
-		&StaticFileImage{"/static/icons/hara.png"},
-		&StaticFileImage{"/static/icons/harada.png"},
-		&StaticFileImage{"/static/icons/hasegawa.png"},
-		&StaticFileImage{"/static/icons/hashimoto.png"},
-		&StaticFileImage{"/static/icons/hayashi.png"},
-		&StaticFileImage{"/static/icons/takefusa.png"},
-		&StaticFileImage{"/static/icons/hirano.png"},
-		&StaticFileImage{"/static/icons/ikeda.png"},
-		&StaticFileImage{"/static/icons/imai.png"},
-		&StaticFileImage{"/static/icons/inoue.png"},
-		&StaticFileImage{"/static/icons/ishida.png"},
-		&StaticFileImage{"/static/icons/ishii.png"},
-		&StaticFileImage{"/static/icons/ishikawa.png"},
-		&StaticFileImage{"/static/icons/ito.png"},
-		&StaticFileImage{"/static/icons/iwasaki.png"},
-		&StaticFileImage{"/static/icons/kaneko.png"},
-		&StaticFileImage{"/static/icons/kato.png"},
-		&StaticFileImage{"/static/icons/kikuchi.png"},
-		&StaticFileImage{"/static/icons/kimura.png"},
-		&StaticFileImage{"/static/icons/kinoshita.png"},
-		&StaticFileImage{"/static/icons/kobayashi.png"},
-		&StaticFileImage{"/static/icons/kojima.png"},
-		&StaticFileImage{"/static/icons/kondo.png"},
-		&StaticFileImage{"/static/icons/kubo.png"},
-		&StaticFileImage{"/static/icons/kudo.png"},
-		&StaticFileImage{"/static/icons/maeda.png"},
-		&StaticFileImage{"/static/icons/maruyama.png"},
-		&StaticFileImage{"/static/icons/masuda.png"},
-		&StaticFileImage{"/static/icons/matsuda.png"},
-		&StaticFileImage{"/static/icons/matsui.png"},
-		&StaticFileImage{"/static/icons/matsumoto.png"},
-		&StaticFileImage{"/static/icons/matsuo.png"},
-		&StaticFileImage{"/static/icons/miura.png"},
-		&StaticFileImage{"/static/icons/miyamoto.png"},
-		&StaticFileImage{"/static/icons/miyazaki.png"},
-		&StaticFileImage{"/static/icons/mori.png"},
-		&StaticFileImage{"/static/icons/morita.png"},
-		&StaticFileImage{"/static/icons/murakami.png"},
-		&StaticFileImage{"/static/icons/murata.png"},
-		&StaticFileImage{"/static/icons/nakagawa.png"},
-		&StaticFileImage{"/static/icons/nakajima.png"},
-		&StaticFileImage{"/static/icons/nakamura.png"},
-		&StaticFileImage{"/static/icons/nakano.png"},
-		&StaticFileImage{"/static/icons/nakayama.png"},
-		&StaticFileImage{"/static/icons/nishimura.png"},
-		&StaticFileImage{"/static/icons/noguchi.png"},
-		&StaticFileImage{"/static/icons/nomura.png"},
-		&StaticFileImage{"/static/icons/ogawa.png"},
-		&StaticFileImage{"/static/icons/okada.png"},
-		&StaticFileImage{"/static/icons/okamoto.png"},
-		&StaticFileImage{"/static/icons/ono.png"},
-		&StaticFileImage{"/static/icons/oonishi.png"},
-		&StaticFileImage{"/static/icons/oono.png"},
-		&StaticFileImage{"/static/icons/oota.png"},
-		&StaticFileImage{"/static/icons/ootsuka.png"},
-		&StaticFileImage{"/static/icons/pulse.png"},
-		&StaticFileImage{"/static/icons/saito.png"},
-		&StaticFileImage{"/static/icons/sakai.png"},
-		&StaticFileImage{"/static/icons/sakamoto.png"},
-		&StaticFileImage{"/static/icons/sakurai.png"},
-		&StaticFileImage{"/static/icons/sano.png"},
-		&StaticFileImage{"/static/icons/sasaki.png"},
-		&StaticFileImage{"/static/icons/sato.png"},
-		&StaticFileImage{"/static/icons/shibata.png"},
-		&StaticFileImage{"/static/icons/shimizu.png"},
-		&StaticFileImage{"/static/icons/sugawara.png"},
-		&StaticFileImage{"/static/icons/sugimoto.png"},
-		&StaticFileImage{"/static/icons/sugiyama.png"},
-		&StaticFileImage{"/static/icons/suzuki.png"},
-		&StaticFileImage{"/static/icons/takada.png"},
-		&StaticFileImage{"/static/icons/takagi.png"},
-		&StaticFileImage{"/static/icons/takahashi.png"},
-		&StaticFileImage{"/static/icons/takeda.png"},
-		&StaticFileImage{"/static/icons/takeuchi.png"},
-		&StaticFileImage{"/static/icons/tamura.png"},
-		&StaticFileImage{"/static/icons/tanaka.png"},
-		&StaticFileImage{"/static/icons/taniguchi.png"},
-		&StaticFileImage{"/static/icons/uchida.png"},
-		&StaticFileImage{"/static/icons/ueda.png"},
-		&StaticFileImage{"/static/icons/ueno.png"},
-		&StaticFileImage{"/static/icons/wada.png"},
-		&StaticFileImage{"/static/icons/watanabe.png"},
-		&StaticFileImage{"/static/icons/yamada.png"},
-		&StaticFileImage{"/static/icons/yamaguchi.png"},
-		&StaticFileImage{"/static/icons/yamamoto.png"},
-		&StaticFileImage{"/static/icons/yamashita.png"},
-		&StaticFileImage{"/static/icons/yamazaki.png"},
-		&StaticFileImage{"/static/icons/yokoyama.png"},
-		&StaticFileImage{"/static/icons/yoshida.png"},
+		&StaticFileImage{"/static/icons/abe.png", 15344, "3b94553b2ff25dcd6a4d6fa473d2f593"},
+		&StaticFileImage{"/static/icons/ando.png", 12937, "f49fe38695e2ee99c5f250f4dc8cb6a0"},
+		&StaticFileImage{"/static/icons/aoki.png", 8133, "c3b257b0b355cda6b75479ec2a677d68"},
+		&StaticFileImage{"/static/icons/arai.png", 9795, "24f123e1eb97fdfbfb43e0128458928b"},
+		&StaticFileImage{"/static/icons/chiba.png", 14400, "32da70217059466b1179fd8a766bf9d7"},
+		&StaticFileImage{"/static/icons/default-icon.png", 333156, "eb9118558814f2321969029ed6d18e3d"},
+		&StaticFileImage{"/static/icons/endo.png", 21541, "0a6714c28c66b00446a5b4b7a29e0a20"},
+		&StaticFileImage{"/static/icons/favicon.ico", 9662, "ae82cd648effd19be68486e9c4966c0d"},
+		&StaticFileImage{"/static/icons/fujii.png", 5868, "751d7666a23536dbf82294c94352d20f"},
+		&StaticFileImage{"/static/icons/fujimoto.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/fujita.png", 10750, "0da543439f4d61efc89939cb86c30ae8"},
+		&StaticFileImage{"/static/icons/fujiwara.png", 14672, "3f430e00cd4ae169b6725e30e9c0e333"},
+		&StaticFileImage{"/static/icons/fukuda.png", 13868, "384bf9dfabacc641f46595be9c145cce"},
+		&StaticFileImage{"/static/icons/goto.png", 7912, "c4726de91e0494dc6b9c35f282124e84"},
+		&StaticFileImage{"/static/icons/hamada.png", 13891, "712e55710307cbc6566cdee00a99d989"},
+		&StaticFileImage{"/static/icons/hara.png", 22280, "060f1570acfb4a9930116ee6f3bd1925"},
+		&StaticFileImage{"/static/icons/harada.png", 20445, "f10658bb38af40755fcc9331b2f4ccce"},
+		&StaticFileImage{"/static/icons/hasegawa.png", 8144, "6a663bc973679f7205bdf1c5061cd4ea"},
+		&StaticFileImage{"/static/icons/hashimoto.png", 4664, "b7a513d3e03a77487005ab3753b87a7c"},
+		&StaticFileImage{"/static/icons/hayashi.png", 13442, "025a628f1fe2ec3a314628191fbd1964"},
+		&StaticFileImage{"/static/icons/hirano.png", 12750, "5519528094628b259e92de548ccdf91a"},
+		&StaticFileImage{"/static/icons/ikeda.png", 8344, "c4e480b9588016de201ec3f1e6b5320c"},
+		&StaticFileImage{"/static/icons/imai.png", 8344, "c4e480b9588016de201ec3f1e6b5320c"},
+		&StaticFileImage{"/static/icons/inoue.png", 11037, "eb55bab20e4f9bb98c38c701e8ae687d"},
+		&StaticFileImage{"/static/icons/ishida.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&StaticFileImage{"/static/icons/ishii.png", 10176, "c6f582b5e87a671a8fa0b5d49efc7048"},
+		&StaticFileImage{"/static/icons/ishikawa.png", 4664, "b7a513d3e03a77487005ab3753b87a7c"},
+		&StaticFileImage{"/static/icons/ito.png", 3811, "bfe2466ba69fa5ac46394c3f7e08df52"},
+		&StaticFileImage{"/static/icons/iwasaki.png", 3980, "12597838e094665f55781ca624b373bd"},
+		&StaticFileImage{"/static/icons/kaneko.png", 16957, "bd314ffcaf99d4a9af257abab81ac006"},
+		&StaticFileImage{"/static/icons/kato.png", 11502, "e9cd953aa6f471e9d5eb4b1ade3bdabf"},
+		&StaticFileImage{"/static/icons/kikuchi.png", 21541, "0a6714c28c66b00446a5b4b7a29e0a20"},
+		&StaticFileImage{"/static/icons/kimura.png", 3811, "bfe2466ba69fa5ac46394c3f7e08df52"},
+		&StaticFileImage{"/static/icons/kinoshita.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/kobayashi.png", 12937, "f49fe38695e2ee99c5f250f4dc8cb6a0"},
+		&StaticFileImage{"/static/icons/kojima.png", 15037, "e85f2e3d2f3caa779a5ba278da5730de"},
+		&StaticFileImage{"/static/icons/kondo.png", 13872, "ace14704b0593ee92e35e24e8ff6cd71"},
+		&StaticFileImage{"/static/icons/kubo.png", 6903, "36ebf049b86da03139ded41c3837b2d4"},
+		&StaticFileImage{"/static/icons/kudo.png", 7912, "c4726de91e0494dc6b9c35f282124e84"},
+		&StaticFileImage{"/static/icons/maeda.png", 8083, "b3bf5cdbbbcc3074c3559dd1e67857c1"},
+		&StaticFileImage{"/static/icons/maruyama.png", 21655, "a883c76a8e561017ab996323996abb57"},
+		&StaticFileImage{"/static/icons/masuda.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/matsuda.png", 5944, "adbf338e44b836eba9c73a35d4976b74"},
+		&StaticFileImage{"/static/icons/matsui.png", 6329, "8a2d89f8a7dcb1e368d106815bf9b678"},
+		&StaticFileImage{"/static/icons/matsumoto.png", 22280, "060f1570acfb4a9930116ee6f3bd1925"},
+		&StaticFileImage{"/static/icons/matsuo.png", 17055, "6ce9dbfbcec201760e6d5fd440256bc8"},
+		&StaticFileImage{"/static/icons/miura.png", 13353, "a8e11e016ce94e24ec8ff0fc2c9e5858"},
+		&StaticFileImage{"/static/icons/miyamoto.png", 9099, "14ef9e217ca62f9298712c789a005756"},
+		&StaticFileImage{"/static/icons/miyazaki.png", 15037, "e85f2e3d2f3caa779a5ba278da5730de"},
+		&StaticFileImage{"/static/icons/mori.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/morita.png", 5944, "adbf338e44b836eba9c73a35d4976b74"},
+		&StaticFileImage{"/static/icons/murakami.png", 7733, "88ba4523c983a996335cdda09d39b35b"},
+		&StaticFileImage{"/static/icons/murata.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/nakagawa.png", 17055, "6ce9dbfbcec201760e6d5fd440256bc8"},
+		&StaticFileImage{"/static/icons/nakajima.png", 15344, "3b94553b2ff25dcd6a4d6fa473d2f593"},
+		&StaticFileImage{"/static/icons/nakamura.png", 11577, "5ab629ad8bbcd918d298ebe6073a99b8"},
+		&StaticFileImage{"/static/icons/nakano.png", 6310, "cc148a6fa7eaf3ad43da375e5728f56d"},
+		&StaticFileImage{"/static/icons/nakayama.png", 22280, "060f1570acfb4a9930116ee6f3bd1925"},
+		&StaticFileImage{"/static/icons/nishimura.png", 7912, "c4726de91e0494dc6b9c35f282124e84"},
+		&StaticFileImage{"/static/icons/noguchi.png", 10368, "574be4d4f040671623a65aaeb578d5c3"},
+		&StaticFileImage{"/static/icons/nomura.png", 10805, "71de9c6cf65826690439aa48737ae853"},
+		&StaticFileImage{"/static/icons/ogawa.png", 10805, "71de9c6cf65826690439aa48737ae853"},
+		&StaticFileImage{"/static/icons/okada.png", 7733, "88ba4523c983a996335cdda09d39b35b"},
+		&StaticFileImage{"/static/icons/okamoto.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&StaticFileImage{"/static/icons/ono.png", 20445, "f10658bb38af40755fcc9331b2f4ccce"},
+		&StaticFileImage{"/static/icons/oonishi.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&StaticFileImage{"/static/icons/oono.png", 29739, "0f653cbc884520907c1de78da9d7cf7e"},
+		&StaticFileImage{"/static/icons/oota.png", 15564, "3d788487f779ddcc207dfd98e3c88024"},
+		&StaticFileImage{"/static/icons/ootsuka.png", 9099, "14ef9e217ca62f9298712c789a005756"},
+		&StaticFileImage{"/static/icons/saito.png", 14400, "32da70217059466b1179fd8a766bf9d7"},
+		&StaticFileImage{"/static/icons/sakai.png", 11444, "b98e0cb89206a8db90ed48405a85fb80"},
+		&StaticFileImage{"/static/icons/sakamoto.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&StaticFileImage{"/static/icons/sakurai.png", 14400, "32da70217059466b1179fd8a766bf9d7"},
+		&StaticFileImage{"/static/icons/sano.png", 11037, "eb55bab20e4f9bb98c38c701e8ae687d"},
+		&StaticFileImage{"/static/icons/sasaki.png", 21541, "0a6714c28c66b00446a5b4b7a29e0a20"},
+		&StaticFileImage{"/static/icons/sato.png", 15277, "64fb36d8813b9f9918eff8d49f227132"},
+		&StaticFileImage{"/static/icons/shibata.png", 11577, "5ab629ad8bbcd918d298ebe6073a99b8"},
+		&StaticFileImage{"/static/icons/shimizu.png", 31644, "ced35ed2e9abb948286ed38da00116ab"},
+		&StaticFileImage{"/static/icons/star.png", 8404, "f869f225c88e320753d8c88ee3f8dd6f"},
+		&StaticFileImage{"/static/icons/sugawara.png", 15037, "e85f2e3d2f3caa779a5ba278da5730de"},
+		&StaticFileImage{"/static/icons/sugimoto.png", 9795, "24f123e1eb97fdfbfb43e0128458928b"},
+		&StaticFileImage{"/static/icons/sugiyama.png", 21541, "0a6714c28c66b00446a5b4b7a29e0a20"},
+		&StaticFileImage{"/static/icons/suzuki.png", 9992, "3b7d572ee9110f0a8f1f48ee79cea831"},
+		&StaticFileImage{"/static/icons/takada.png", 7797, "3a803a8573d5c7cd28b48f02f8d347cc"},
+		&StaticFileImage{"/static/icons/takagi.png", 7657, "284a4f7f8615030e055f3b463f0da039"},
+		&StaticFileImage{"/static/icons/takahashi.png", 11577, "5ab629ad8bbcd918d298ebe6073a99b8"},
+		&StaticFileImage{"/static/icons/takeda.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&StaticFileImage{"/static/icons/takefusa.png", 7056, "53dcccbe1c5ecc267a4eb2a0e140fc82"},
+		&StaticFileImage{"/static/icons/takeuchi.png", 11444, "b98e0cb89206a8db90ed48405a85fb80"},
+		&StaticFileImage{"/static/icons/tamura.png", 29739, "0f653cbc884520907c1de78da9d7cf7e"},
+		&StaticFileImage{"/static/icons/tanaka.png", 15277, "64fb36d8813b9f9918eff8d49f227132"},
+		&StaticFileImage{"/static/icons/taniguchi.png", 7531, "61b088ac436d4ab313f8fe424c8e0c49"},
+		&StaticFileImage{"/static/icons/uchida.png", 9099, "14ef9e217ca62f9298712c789a005756"},
+		&StaticFileImage{"/static/icons/ueda.png", 13442, "025a628f1fe2ec3a314628191fbd1964"},
+		&StaticFileImage{"/static/icons/ueno.png", 14672, "3f430e00cd4ae169b6725e30e9c0e333"},
+		&StaticFileImage{"/static/icons/wada.png", 10943, "927f073c075029c9d8538530df377e28"},
+		&StaticFileImage{"/static/icons/watanabe.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&StaticFileImage{"/static/icons/yamada.png", 15344, "3b94553b2ff25dcd6a4d6fa473d2f593"},
+		&StaticFileImage{"/static/icons/yamaguchi.png", 11577, "5ab629ad8bbcd918d298ebe6073a99b8"},
+		&StaticFileImage{"/static/icons/yamamoto.png", 6531, "e495f3c2932b70fc0b331a908b0fdf63"},
+		&StaticFileImage{"/static/icons/yamashita.png", 9846, "59ee53e713fa611fd3366f9ac7442b3f"},
+		&StaticFileImage{"/static/icons/yamazaki.png", 4664, "b7a513d3e03a77487005ab3753b87a7c"},
+		&StaticFileImage{"/static/icons/yokoyama.png", 6903, "36ebf049b86da03139ded41c3837b2d4"},
+		&StaticFileImage{"/static/icons/yoshida.png", 11701, "0955ae59002b70f1edc8132953b610ce"},
 	}
 
 	UploadFileImages = []*UploadFileImage{
-		&UploadFileImage{"/images/f_f_business_14_s512_f_business_14_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_1_s512_f_business_1_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_23_s512_f_business_23_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_32_s512_f_business_32_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_33_s512_f_business_33_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_36_s512_f_business_36_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_3_s512_f_business_3_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_46_s512_f_business_46_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_48_s512_f_business_48_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_4_s512_f_business_4_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_52_s512_f_business_52_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_71_s512_f_business_71_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_74_s512_f_business_74_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_77_s512_f_business_77_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_78_s512_f_business_78_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_80_s512_f_business_80_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_83_s512_f_business_83_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_90_s512_f_business_90_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_92_s512_f_business_92_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_93_s512_f_business_93_0bg.png"},
-		&UploadFileImage{"/images/f_f_business_97_s512_f_business_97_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_101_s512_f_event_101_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_10_s512_f_event_10_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_12_s512_f_event_12_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_16_s512_f_event_16_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_17_s512_f_event_17_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_23_s512_f_event_23_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_25_s512_f_event_25_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_26_s512_f_event_26_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_33_s512_f_event_33_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_35_s512_f_event_35_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_36_s512_f_event_36_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_39_s512_f_event_39_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_43_s512_f_event_43_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_44_s512_f_event_44_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_53_s512_f_event_53_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_54_s512_f_event_54_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_58_s512_f_event_58_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_5_s512_f_event_5_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_63_s512_f_event_63_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_67_s512_f_event_67_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_78_s512_f_event_78_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_82_s512_f_event_82_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_86_s512_f_event_86_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_8_s512_f_event_8_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_91_s512_f_event_91_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_92_s512_f_event_92_0bg.png"},
-		&UploadFileImage{"/images/f_f_event_99_s512_f_event_99_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_106_s512_f_object_106_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_10_s512_f_object_10_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_111_s512_f_object_111_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_115_s512_f_object_115_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_145_s512_f_object_145_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_14_s512_f_object_14_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_156_s512_f_object_156_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_167_s512_f_object_167_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_26_s512_f_object_26_0bg.png"},
-		&UploadFileImage{"/images/f_f_object_41_s512_f_object_41_0bg.png"},
-		&UploadFileImage{"/images/hayashi.png"},
-		&UploadFileImage{"/images/inoue.png"},
-		&UploadFileImage{"/images/ito.png"},
-		&UploadFileImage{"/images/kato.png"},
-		&UploadFileImage{"/images/kimura.png"},
-		&UploadFileImage{"/images/kobayashi.png"},
-		&UploadFileImage{"/images/matsumoto.png"},
-		&UploadFileImage{"/images/nakamura.png"},
-		&UploadFileImage{"/images/saito.png"},
-		&UploadFileImage{"/images/sasaki.png"},
-		&UploadFileImage{"/images/sato.png"},
-		&UploadFileImage{"/images/shimizu.png"},
-		&UploadFileImage{"/images/suzuki.png"},
-		&UploadFileImage{"/images/takahashi.png"},
-		&UploadFileImage{"/images/tanaka.png"},
-		&UploadFileImage{"/images/watanabe.png"},
-		&UploadFileImage{"/images/yamada.png"},
-		&UploadFileImage{"/images/yamaguchi.png"},
-		&UploadFileImage{"/images/yamamoto.png"},
-		&UploadFileImage{"/images/yoshida.png"},
-		&UploadFileImage{"/images/test.png"},
+		&UploadFileImage{"/images/f_f_business_14_s512_f_business_14_0bg.png", 7912, "c4726de91e0494dc6b9c35f282124e84"},
+		&UploadFileImage{"/images/f_f_business_1_s512_f_business_1_0bg.png", 7657, "284a4f7f8615030e055f3b463f0da039"},
+		&UploadFileImage{"/images/f_f_business_23_s512_f_business_23_0bg.png", 9992, "3b7d572ee9110f0a8f1f48ee79cea831"},
+		&UploadFileImage{"/images/f_f_business_32_s512_f_business_32_0bg.png", 5944, "adbf338e44b836eba9c73a35d4976b74"},
+		&UploadFileImage{"/images/f_f_business_33_s512_f_business_33_0bg.png", 7733, "88ba4523c983a996335cdda09d39b35b"},
+		&UploadFileImage{"/images/f_f_business_36_s512_f_business_36_0bg.png", 6531, "e495f3c2932b70fc0b331a908b0fdf63"},
+		&UploadFileImage{"/images/f_f_business_3_s512_f_business_3_0bg.png", 6903, "36ebf049b86da03139ded41c3837b2d4"},
+		&UploadFileImage{"/images/f_f_business_46_s512_f_business_46_0bg.png", 11037, "eb55bab20e4f9bb98c38c701e8ae687d"},
+		&UploadFileImage{"/images/f_f_business_48_s512_f_business_48_0bg.png", 10161, "4a5d07d01b6b1039e68dffa47b478c4e"},
+		&UploadFileImage{"/images/f_f_business_4_s512_f_business_4_0bg.png", 15564, "3d788487f779ddcc207dfd98e3c88024"},
+		&UploadFileImage{"/images/f_f_business_52_s512_f_business_52_0bg.png", 9795, "24f123e1eb97fdfbfb43e0128458928b"},
+		&UploadFileImage{"/images/f_f_business_71_s512_f_business_71_0bg.png", 10943, "927f073c075029c9d8538530df377e28"},
+		&UploadFileImage{"/images/f_f_business_74_s512_f_business_74_0bg.png", 8344, "c4e480b9588016de201ec3f1e6b5320c"},
+		&UploadFileImage{"/images/f_f_business_77_s512_f_business_77_0bg.png", 10262, "aa804e71428bbe202affbacd6f4423d4"},
+		&UploadFileImage{"/images/f_f_business_78_s512_f_business_78_0bg.png", 10176, "c6f582b5e87a671a8fa0b5d49efc7048"},
+		&UploadFileImage{"/images/f_f_business_80_s512_f_business_80_0bg.png", 3980, "12597838e094665f55781ca624b373bd"},
+		&UploadFileImage{"/images/f_f_business_83_s512_f_business_83_0bg.png", 8396, "a9829d2e30fca99c375f72e4fbe97db7"},
+		&UploadFileImage{"/images/f_f_business_90_s512_f_business_90_0bg.png", 9170, "fc85b55aadf5191590839e4f9b565ffd"},
+		&UploadFileImage{"/images/f_f_business_92_s512_f_business_92_0bg.png", 9171, "22a1480a48829c2675a449470d3a15fc"},
+		&UploadFileImage{"/images/f_f_business_93_s512_f_business_93_0bg.png", 8083, "b3bf5cdbbbcc3074c3559dd1e67857c1"},
+		&UploadFileImage{"/images/f_f_business_97_s512_f_business_97_0bg.png", 5230, "234bdf5ef8a662b5f6c8861247b5246d"},
+		&UploadFileImage{"/images/f_f_event_101_s512_f_event_101_0bg.png", 21655, "a883c76a8e561017ab996323996abb57"},
+		&UploadFileImage{"/images/f_f_event_10_s512_f_event_10_0bg.png", 11577, "5ab629ad8bbcd918d298ebe6073a99b8"},
+		&UploadFileImage{"/images/f_f_event_12_s512_f_event_12_0bg.png", 15277, "64fb36d8813b9f9918eff8d49f227132"},
+		&UploadFileImage{"/images/f_f_event_16_s512_f_event_16_0bg.png", 7056, "53dcccbe1c5ecc267a4eb2a0e140fc82"},
+		&UploadFileImage{"/images/f_f_event_17_s512_f_event_17_0bg.png", 31644, "ced35ed2e9abb948286ed38da00116ab"},
+		&UploadFileImage{"/images/f_f_event_23_s512_f_event_23_0bg.png", 12809, "9aad20152f7bca1211a319ca95b2513b"},
+		&UploadFileImage{"/images/f_f_event_25_s512_f_event_25_0bg.png", 14672, "3f430e00cd4ae169b6725e30e9c0e333"},
+		&UploadFileImage{"/images/f_f_event_26_s512_f_event_26_0bg.png", 20445, "f10658bb38af40755fcc9331b2f4ccce"},
+		&UploadFileImage{"/images/f_f_event_33_s512_f_event_33_0bg.png", 21541, "0a6714c28c66b00446a5b4b7a29e0a20"},
+		&UploadFileImage{"/images/f_f_event_35_s512_f_event_35_0bg.png", 11502, "e9cd953aa6f471e9d5eb4b1ade3bdabf"},
+		&UploadFileImage{"/images/f_f_event_36_s512_f_event_36_0bg.png", 11444, "b98e0cb89206a8db90ed48405a85fb80"},
+		&UploadFileImage{"/images/f_f_event_39_s512_f_event_39_0bg.png", 4664, "b7a513d3e03a77487005ab3753b87a7c"},
+		&UploadFileImage{"/images/f_f_event_43_s512_f_event_43_0bg.png", 9963, "b633685e5c2dcd34d7e74d8ca034bacd"},
+		&UploadFileImage{"/images/f_f_event_44_s512_f_event_44_0bg.png", 14400, "32da70217059466b1179fd8a766bf9d7"},
+		&UploadFileImage{"/images/f_f_event_53_s512_f_event_53_0bg.png", 10750, "0da543439f4d61efc89939cb86c30ae8"},
+		&UploadFileImage{"/images/f_f_event_54_s512_f_event_54_0bg.png", 7531, "61b088ac436d4ab313f8fe424c8e0c49"},
+		&UploadFileImage{"/images/f_f_event_58_s512_f_event_58_0bg.png", 12750, "5519528094628b259e92de548ccdf91a"},
+		&UploadFileImage{"/images/f_f_event_5_s512_f_event_5_0bg.png", 15340, "1d9869eb1857d3e5f7543fbe8cd1a6bc"},
+		&UploadFileImage{"/images/f_f_event_63_s512_f_event_63_0bg.png", 13891, "712e55710307cbc6566cdee00a99d989"},
+		&UploadFileImage{"/images/f_f_event_67_s512_f_event_67_0bg.png", 13442, "025a628f1fe2ec3a314628191fbd1964"},
+		&UploadFileImage{"/images/f_f_event_78_s512_f_event_78_0bg.png", 14600, "06321b1b22d037a4ad6c3d99c308fee8"},
+		&UploadFileImage{"/images/f_f_event_82_s512_f_event_82_0bg.png", 17115, "bad3ea15e9f0f62e78e9f806956961da"},
+		&UploadFileImage{"/images/f_f_event_86_s512_f_event_86_0bg.png", 9846, "59ee53e713fa611fd3366f9ac7442b3f"},
+		&UploadFileImage{"/images/f_f_event_8_s512_f_event_8_0bg.png", 29739, "0f653cbc884520907c1de78da9d7cf7e"},
+		&UploadFileImage{"/images/f_f_event_91_s512_f_event_91_0bg.png", 10805, "71de9c6cf65826690439aa48737ae853"},
+		&UploadFileImage{"/images/f_f_event_92_s512_f_event_92_0bg.png", 12937, "f49fe38695e2ee99c5f250f4dc8cb6a0"},
+		&UploadFileImage{"/images/f_f_event_99_s512_f_event_99_0bg.png", 22280, "060f1570acfb4a9930116ee6f3bd1925"},
+		&UploadFileImage{"/images/f_f_object_106_s512_f_object_106_0bg.png", 17055, "6ce9dbfbcec201760e6d5fd440256bc8"},
+		&UploadFileImage{"/images/f_f_object_10_s512_f_object_10_0bg.png", 13868, "384bf9dfabacc641f46595be9c145cce"},
+		&UploadFileImage{"/images/f_f_object_111_s512_f_object_111_0bg.png", 15037, "e85f2e3d2f3caa779a5ba278da5730de"},
+		&UploadFileImage{"/images/f_f_object_115_s512_f_object_115_0bg.png", 10368, "574be4d4f040671623a65aaeb578d5c3"},
+		&UploadFileImage{"/images/f_f_object_145_s512_f_object_145_0bg.png", 14287, "f642fd3935eb9c9c3ac5e0e09f266434"},
+		&UploadFileImage{"/images/f_f_object_14_s512_f_object_14_0bg.png", 8753, "a8c8026539091068ed1eb5ec449acd6b"},
+		&UploadFileImage{"/images/f_f_object_156_s512_f_object_156_0bg.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&UploadFileImage{"/images/f_f_object_167_s512_f_object_167_0bg.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&UploadFileImage{"/images/f_f_object_26_s512_f_object_26_0bg.png", 7797, "3a803a8573d5c7cd28b48f02f8d347cc"},
+		&UploadFileImage{"/images/f_f_object_41_s512_f_object_41_0bg.png", 6560, "bc9d738f9f44d4a5bf1281a3ea454363"},
+		&UploadFileImage{"/images/hayashi.png", 16957, "bd314ffcaf99d4a9af257abab81ac006"},
+		&UploadFileImage{"/images/inoue.png", 5305, "4fea2a0bd7a4d8dfc05b3f83e59f153c"},
+		&UploadFileImage{"/images/ito.png", 6329, "8a2d89f8a7dcb1e368d106815bf9b678"},
+		&UploadFileImage{"/images/kato.png", 13264, "49c95915c61a3e233701e09ca12a33b6"},
+		&UploadFileImage{"/images/kimura.png", 11054, "c51b5e9f15353876a323974e725a2dff"},
+		&UploadFileImage{"/images/kobayashi.png", 5708, "ab28f7d769a1be7ad80585f63acbfdeb"},
+		&UploadFileImage{"/images/matsumoto.png", 11257, "a9c38905a05a6344c703bfe20e0e4906"},
+		&UploadFileImage{"/images/nakamura.png", 11688, "015d5c4e3a7c859e0a97a0b1a8cdb6d5"},
+		&UploadFileImage{"/images/saito.png", 6310, "cc148a6fa7eaf3ad43da375e5728f56d"},
+		&UploadFileImage{"/images/sasaki.png", 11701, "0955ae59002b70f1edc8132953b610ce"},
+		&UploadFileImage{"/images/sato.png", 13483, "f3e36cb4095293dcd01f3ca82a413e7b"},
+		&UploadFileImage{"/images/shimizu.png", 3811, "bfe2466ba69fa5ac46394c3f7e08df52"},
+		&UploadFileImage{"/images/suzuki.png", 8133, "c3b257b0b355cda6b75479ec2a677d68"},
+		&UploadFileImage{"/images/takahashi.png", 5868, "751d7666a23536dbf82294c94352d20f"},
+		&UploadFileImage{"/images/tanaka.png", 13872, "ace14704b0593ee92e35e24e8ff6cd71"},
+		&UploadFileImage{"/images/watanabe.png", 9099, "14ef9e217ca62f9298712c789a005756"},
+		&UploadFileImage{"/images/yamada.png", 13353, "a8e11e016ce94e24ec8ff0fc2c9e5858"},
+		&UploadFileImage{"/images/yamaguchi.png", 8144, "6a663bc973679f7205bdf1c5061cd4ea"},
+		&UploadFileImage{"/images/yamamoto.png", 15344, "3b94553b2ff25dcd6a4d6fa473d2f593"},
+		&UploadFileImage{"/images/yoshida.png", 9248, "b8a3b9dffffb91ec04e08d8de5f95a97"},
 	}
 )
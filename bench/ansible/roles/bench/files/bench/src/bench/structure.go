@@ -1,7 +1,6 @@
 package bench
 
 import (
-	"math/rand"
 	"sync"
 )
 
@@ -25,14 +24,19 @@ type State struct {
 }
 
 func (s *State) Init() {
+	s.InitWithUsers(DataSet.Users)
+}
+
+// 分散実行では DataSet のユーザを分けてそれぞれのノードで使う
+func (s *State) InitWithUsers(users []*AppUser) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	s.users = append(s.users, DataSet.Users...)
+	s.users = append(s.users, users...)
 	s.userMap = map[string]*AppUser{}
 	s.checkerMap = map[*AppUser]*Checker{}
 
-	for _, u := range DataSet.Users {
+	for _, u := range users {
 		s.userMap[u.Name] = u
 	}
 }
@@ -46,7 +50,7 @@ func (s *State) PopRandomUser() (*AppUser, *Checker, func()) {
 		return nil, nil, nil
 	}
 
-	i := rand.Intn(n)
+	i := RandIntn(n)
 	u := s.users[i]
 
 	s.users[i] = s.users[n-1]
@@ -77,8 +81,25 @@ func (s *State) getCheckerLocked(u *AppUser) *Checker {
 	if !ok {
 		checker = NewChecker()
 		checker.debugHeaders["X-Username"] = u.Name
+		checker.userName = u.Name
 		s.checkerMap[u] = checker
 	}
 
 	return checker
 }
+
+// 登録済みのユーザ数 (シナリオで使用中のものを含む)
+func (s *State) UserCount() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.userMap)
+}
+
+// PopRandomUser で取り出せるユーザ数
+func (s *State) FreeUserCount() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return len(s.users)
+}
@@ -0,0 +1,331 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bench/latency"
+)
+
+// CheckerTransport を通ったリクエストの記録
+// トレースファイルは1行1レコードの JSON で、ファイル名が .gz で終わる場合は gzip で圧縮する
+type TraceRecord struct {
+	Offset time.Duration `json:"t"`
+	User   string        `json:"u,omitempty"`
+	Method string        `json:"m"`
+	Path   string        `json:"p"`
+	Header http.Header   `json:"h,omitempty"`
+	Body   []byte        `json:"b,omitempty"`
+	// 記録時のレスポンスのステータスコード (レスポンスがなかった場合は 0)
+	Status int `json:"s,omitempty"`
+}
+
+type traceUserKey struct{}
+
+// 記録しないヘッダ (再生時はそれぞれのセッションで付け直す)
+var traceIgnoreHeaders = []string{"Cookie", "X-Request-Id", "User-Agent"}
+
+var (
+	traceMtx   sync.Mutex
+	traceStart time.Time
+	traceFile  *os.File
+	traceGzip  *gzip.Writer
+	traceBuf   *bufio.Writer
+	traceEnc   *json.Encoder
+)
+
+func StartTraceRecording(path string) error {
+	traceMtx.Lock()
+	defer traceMtx.Unlock()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	traceFile = f
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".gz") {
+		traceGzip = gzip.NewWriter(f)
+		w = traceGzip
+	}
+	traceBuf = bufio.NewWriter(w)
+	traceEnc = json.NewEncoder(traceBuf)
+	traceStart = time.Now()
+	return nil
+}
+
+func StopTraceRecording() error {
+	traceMtx.Lock()
+	defer traceMtx.Unlock()
+
+	if traceFile == nil {
+		return nil
+	}
+
+	err := traceBuf.Flush()
+	if traceGzip != nil {
+		if e := traceGzip.Close(); err == nil {
+			err = e
+		}
+	}
+	if e := traceFile.Close(); err == nil {
+		err = e
+	}
+	traceFile, traceGzip, traceBuf, traceEnc = nil, nil, nil, nil
+	return err
+}
+
+func isTraceRecording() bool {
+	traceMtx.Lock()
+	defer traceMtx.Unlock()
+
+	return traceEnc != nil
+}
+
+func recordTrace(req *http.Request, res *http.Response, sentAt time.Time) {
+	if !isTraceRecording() {
+		return
+	}
+
+	r := &TraceRecord{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Header: http.Header{},
+	}
+	if u, ok := req.Context().Value(traceUserKey{}).(string); ok {
+		r.User = u
+	}
+	if res != nil {
+		r.Status = res.StatusCode
+	}
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	for _, k := range traceIgnoreHeaders {
+		r.Header.Del(k)
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			r.Body, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	traceMtx.Lock()
+	defer traceMtx.Unlock()
+
+	if traceEnc == nil {
+		return
+	}
+	r.Offset = sentAt.Sub(traceStart)
+	if err := traceEnc.Encode(r); err != nil {
+		log.Println("trace:", err)
+	}
+}
+
+func LoadTraceFile(path string) ([]*TraceRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var records []*TraceRecord
+	dec := json.NewDecoder(r)
+	for {
+		rec := new(TraceRecord)
+		err := dec.Decode(rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+type ReplayResult struct {
+	Requests int `json:"requests"`
+	// レスポンスが得られなかったもの
+	Failures int `json:"failures"`
+	// 5xx が返ったもの
+	ServerErrors int `json:"server_errors"`
+	// 記録時とステータスコードが違ったもの ("GET /login 200 -> 500" の形で最大 replayMismatchSamples 件)
+	Mismatches       int            `json:"mismatches"`
+	MismatchExamples []string       `json:"mismatch_examples,omitempty"`
+	StatusCounts     map[string]int `json:"status_counts"`
+	// 記録時のタイミングからの遅れの最大値
+	MaxLag time.Duration `json:"max_lag"`
+}
+
+const replayMismatchSamples = 10
+
+var (
+	replayCsrfInputReg     = regexp.MustCompile(`name="csrf_token"[^>]*value="([^"]*)"`)
+	replayCsrfMultipartReg = regexp.MustCompile(`(name="csrf_token"\r\n\r\n)[^\r]*`)
+)
+
+// トレースをユーザごとのセッションで記録時と同じ順序で再生する
+// speed が 2 なら記録時の倍の速さで送る
+// csrf_token は再生中のセッションで最後に取得したものに置き換える
+func Replay(ctx context.Context, records []*TraceRecord, speed float64) *ReplayResult {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	byUser := map[string][]*TraceRecord{}
+	var users []string
+	for _, r := range records {
+		if _, ok := byUser[r.User]; !ok {
+			users = append(users, r.User)
+		}
+		byUser[r.User] = append(byUser[r.User], r)
+	}
+
+	result := &ReplayResult{StatusCounts: map[string]int{}}
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for _, u := range users {
+		wg.Add(1)
+		go func(recs []*TraceRecord) {
+			defer wg.Done()
+
+			jar, _ := cookiejar.New(&cookiejar.Options{})
+			client := &http.Client{
+				Transport: transport,
+				Jar:       jar,
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+			csrfToken := ""
+
+			for _, r := range recs {
+				at := start.Add(time.Duration(float64(r.Offset) / speed))
+				select {
+				case <-time.After(time.Until(at)):
+				case <-ctx.Done():
+					return
+				}
+				lag := time.Since(at)
+
+				status, token, err := replayRequest(ctx, client, r, csrfToken)
+				if token != "" {
+					csrfToken = token
+				}
+
+				mtx.Lock()
+				result.Requests++
+				if err != nil {
+					result.Failures++
+					result.StatusCounts["error"]++
+				} else {
+					result.StatusCounts[strconv.Itoa(status)]++
+					if 500 <= status {
+						result.ServerErrors++
+					}
+					// ステータスコードを記録していない古いトレースは比べない
+					if r.Status != 0 && r.Status != status {
+						result.Mismatches++
+						if len(result.MismatchExamples) < replayMismatchSamples {
+							result.MismatchExamples = append(result.MismatchExamples,
+								fmt.Sprintf("%s %s %d -> %d", r.Method, r.Path, r.Status, status))
+						}
+					}
+				}
+				if result.MaxLag < lag {
+					result.MaxLag = lag
+				}
+				mtx.Unlock()
+			}
+		}(byUser[u])
+	}
+
+	wg.Wait()
+	return result
+}
+
+func replayRequest(ctx context.Context, client *http.Client, r *TraceRecord, csrfToken string) (int, string, error) {
+	body := r.Body
+	if csrfToken != "" && len(body) != 0 {
+		ctype := r.Header.Get("Content-Type")
+		if strings.HasPrefix(ctype, "application/x-www-form-urlencoded") {
+			if form, err := url.ParseQuery(string(body)); err == nil && form.Get("csrf_token") != "" {
+				form.Set("csrf_token", csrfToken)
+				body = []byte(form.Encode())
+			}
+		} else if strings.HasPrefix(ctype, "multipart/form-data") {
+			body = replayCsrfMultipartReg.ReplaceAll(body, []byte("${1}"+csrfToken))
+		}
+	}
+
+	var reqBody io.Reader
+	if len(body) != 0 {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(r.Method, "http://"+HisubaAppHost+r.Path, reqBody)
+	if err != nil {
+		return 0, "", err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	timeout := GetTimeout
+	if req.Method == http.MethodPost {
+		timeout = PostTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	latency.Record(req.Method+"|"+NormalizePath(r.Path), time.Since(start))
+	if err != nil {
+		return 0, "", err
+	}
+
+	token := ""
+	if m := replayCsrfInputReg.FindSubmatch(b); m != nil {
+		token = string(m[1])
+	}
+	return res.StatusCode, token, nil
+}
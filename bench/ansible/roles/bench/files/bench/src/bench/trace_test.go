@@ -0,0 +1,43 @@
+package bench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplayComparesStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(200)
+		case "/moved":
+			w.WriteHeader(404)
+		default:
+			w.WriteHeader(500)
+		}
+	}))
+	defer srv.Close()
+	SetTargetHosts([]string{strings.TrimPrefix(srv.URL, "http://")})
+
+	records := []*TraceRecord{
+		{User: "a", Method: "GET", Path: "/ok", Status: 200},
+		{User: "a", Method: "GET", Path: "/moved", Status: 200},
+		{User: "b", Method: "GET", Path: "/broken", Status: 500},
+		// ステータスコードのない古いトレースは比べない
+		{User: "b", Method: "GET", Path: "/moved"},
+	}
+	r := Replay(context.Background(), records, 100)
+
+	if r.Requests != 4 || r.Failures != 0 {
+		t.Fatalf("requests=%d failures=%d, want 4 0", r.Requests, r.Failures)
+	}
+	if r.ServerErrors != 1 {
+		t.Errorf("ServerErrors = %d, want 1", r.ServerErrors)
+	}
+	if r.Mismatches != 1 || len(r.MismatchExamples) != 1 || r.MismatchExamples[0] != "GET /moved 200 -> 404" {
+		t.Errorf("Mismatches = %d %v, want 1 [GET /moved 200 -> 404]", r.Mismatches, r.MismatchExamples)
+	}
+}
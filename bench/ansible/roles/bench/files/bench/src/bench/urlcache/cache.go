@@ -5,13 +5,24 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
-
-	"github.com/marcw/cachecontrol"
+	"time"
 )
 
-// 2017/10/20 inada-s Expireを設定したほうがリクエスト数が減ってスコアが下がる現象がみられたため、Expiresは見ないことにした。
+// RFC 7234 のキャッシュ
+//
+// 鮮度 (freshness) は次の順に決める。ヒューリスティックな鮮度 (Last-Modified からの推定) は使わない
+//   1. 共有キャッシュ (gCache) では s-maxage
+//   2. max-age
+//   3. Expires - Date
+// no-store のレスポンスは保存しない。no-cache のレスポンスは保存するが、常に再検証する
+// 鮮度切れのレスポンスをそのまま使うことはないので、must-revalidate は常に守られる
+// Vary に "*" があるレスポンスはどのリクエストにも一致しない
+//
+// 以前 (2017/10/20 inada-s) は Expires を設定するとリクエスト数が減ってスコアが下がるため、Expires を見ていなかった
+// 今は Expires も上の通り鮮度に使い、キャッシュでリクエストを省略した分の点数は -score-config の skipped_weight で調整する
 
 type CacheStore struct {
 	sync.Mutex
@@ -47,31 +58,234 @@ func (c *CacheStore) Del(key string) {
 	c.Unlock()
 }
 
+// Cache-Control のディレクティブ。名前は小文字で、値のないものは空文字列
+type CacheControl map[string]string
+
+func ParseCacheControl(header http.Header) CacheControl {
+	cc := CacheControl{}
+	for _, line := range header["Cache-Control"] {
+		for _, d := range strings.Split(line, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, value := d, ""
+			if i := strings.Index(d, "="); i >= 0 {
+				name, value = strings.TrimSpace(d[:i]), strings.Trim(strings.TrimSpace(d[i+1:]), `"`)
+			}
+			name = strings.ToLower(name)
+			// 重複した場合は最初のものを使う
+			if _, ok := cc[name]; !ok {
+				cc[name] = value
+			}
+		}
+	}
+	return cc
+}
+
+func (cc CacheControl) Has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// 秒数のディレクティブ。値が不正な場合は 0 秒 (鮮度切れ) として扱う
+func (cc CacheControl) Seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
 type URLCache struct {
 	LastModified string
 	Etag         string
-	CacheControl *cachecontrol.CacheControl
+	CacheControl CacheControl
 	MD5          string
+
+	// 鮮度の計算に使う値
+	ResponseTime time.Time
+	Date         time.Time
+	Age          time.Duration
+	Expires      time.Time
+	HasExpires   bool
+
+	// Vary で指定されたリクエストヘッダの値
+	Vary map[string]string
+	// Vary: * の場合
+	VaryAll bool
 }
 
-func NewURLCache(res *http.Response, body *bytes.Buffer) (*URLCache, string) {
+// 保存できないレスポンスの場合は nil を返す
+// requestTime はリクエストを送った時刻で、Age の補正に使う
+func NewURLCache(res *http.Response, body *bytes.Buffer, requestTime time.Time) (*URLCache, string) {
 	md5Sum := md5.Sum(body.Bytes())
 	hash := hex.EncodeToString(md5Sum[:])
-	ccs := res.Header["Cache-Control"]
-	directive := strings.Join(ccs, " ")
-	cc := cachecontrol.Parse(directive)
-	noCache, _ := cc.NoCache()
 
-	if len(directive) == 0 || noCache || cc.NoStore() {
+	cc := ParseCacheControl(res.Header)
+	if cc.Has("no-store") {
 		return nil, hash
 	}
 
-	return &URLCache{
+	c := &URLCache{
 		LastModified: res.Header.Get("Last-Modified"),
 		Etag:         res.Header.Get("ETag"),
-		CacheControl: &cc,
+		CacheControl: cc,
 		MD5:          hash,
-	}, hash
+	}
+	c.updateFreshness(res.Header, requestTime, time.Now())
+
+	// 再検証も再利用もできないものは保存しても意味がない
+	if c.LastModified == "" && c.Etag == "" && !c.hasExplicitExpiration() {
+		return nil, hash
+	}
+
+	for _, line := range res.Header["Vary"] {
+		for _, name := range strings.Split(line, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				c.VaryAll = true
+				continue
+			}
+			if c.Vary == nil {
+				c.Vary = map[string]string{}
+			}
+			var v string
+			if res.Request != nil {
+				v = strings.Join(res.Request.Header[http.CanonicalHeaderKey(name)], ", ")
+			}
+			c.Vary[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+
+	return c, hash
+}
+
+func (c *URLCache) updateFreshness(header http.Header, requestTime, responseTime time.Time) {
+	c.ResponseTime = responseTime
+	c.Date = responseTime
+	if t, err := http.ParseTime(header.Get("Date")); err == nil {
+		c.Date = t
+	}
+
+	c.Age = 0
+	if v := header.Get("Age"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			c.Age = time.Duration(n) * time.Second
+		}
+	}
+	// RFC 7234 4.2.3 corrected_initial_age
+	apparentAge := c.ResponseTime.Sub(c.Date)
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	correctedAge := c.Age + c.ResponseTime.Sub(requestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	c.Age = correctedAge
+
+	c.Expires, c.HasExpires = time.Time{}, false
+	if v, ok := header["Expires"]; ok {
+		c.HasExpires = true
+		// "0" など不正な値は過去の時刻として扱う
+		if t, err := http.ParseTime(strings.Join(v, "")); err == nil {
+			c.Expires = t
+		}
+	}
+}
+
+func (c *URLCache) hasExplicitExpiration() bool {
+	return c.CacheControl.Has("max-age") || c.CacheControl.Has("s-maxage") || c.HasExpires
+}
+
+// 共有キャッシュに保存してよいか
+func (c *URLCache) Public() bool {
+	return c.CacheControl.Has("public") && !c.CacheControl.Has("private")
+}
+
+// immutable なレスポンスは鮮度がある間は再読み込みでも再検証しない (RFC 8246)
+func (c *URLCache) Immutable() bool {
+	return c.CacheControl.Has("immutable")
+}
+
+func (c *URLCache) freshnessLifetime(shared bool) time.Duration {
+	if shared {
+		if d, ok := c.CacheControl.Seconds("s-maxage"); ok {
+			return d
+		}
+	}
+	if d, ok := c.CacheControl.Seconds("max-age"); ok {
+		return d
+	}
+	if c.HasExpires {
+		return c.Expires.Sub(c.Date)
+	}
+	return 0
+}
+
+// now の時点で再検証せずに使えるか
+// shared は共有キャッシュ (gCache) の場合に true にする
+func (c *URLCache) Fresh(now time.Time, shared bool) bool {
+	if c.CacheControl.Has("no-cache") {
+		return false
+	}
+	if shared && c.CacheControl.Has("proxy-revalidate") && !c.hasExplicitExpiration() {
+		return false
+	}
+	currentAge := c.Age + now.Sub(c.ResponseTime)
+	return c.freshnessLifetime(shared) > currentAge
+}
+
+// Vary で指定されたヘッダがキャッシュしたときのリクエストと一致するか
+func (c *URLCache) Matches(req *http.Request) bool {
+	if c.VaryAll {
+		return false
+	}
+	for name, v := range c.Vary {
+		if strings.Join(req.Header[name], ", ") != v {
+			return false
+		}
+	}
+	return true
+}
+
+// 304 のレスポンスで鮮度を更新したコピーを返す (RFC 7234 4.3.4)
+// 保存済みのものは他の goroutine からも参照されるので書き換えない
+func (c *URLCache) Refresh(res *http.Response, requestTime time.Time) *URLCache {
+	n := *c
+	if _, ok := res.Header["Cache-Control"]; ok {
+		n.CacheControl = ParseCacheControl(res.Header)
+		if n.CacheControl.Has("no-store") {
+			return nil
+		}
+	}
+	if v := res.Header.Get("ETag"); v != "" {
+		n.Etag = v
+	}
+	if v := res.Header.Get("Last-Modified"); v != "" {
+		n.LastModified = v
+	}
+	h := http.Header{}
+	for _, name := range []string{"Date", "Age", "Expires"} {
+		if v, ok := res.Header[name]; ok {
+			h[name] = v
+		}
+	}
+	// 304 に Expires がなければ保存済みの値を引き継ぐ
+	expires, hasExpires := n.Expires, n.HasExpires
+	n.updateFreshness(h, requestTime, time.Now())
+	if !n.HasExpires {
+		n.Expires, n.HasExpires = expires, hasExpires
+	}
+	return &n
 }
 
 func (c *URLCache) ApplyRequest(req *http.Request) {
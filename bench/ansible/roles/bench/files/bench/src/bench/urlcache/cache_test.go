@@ -0,0 +1,137 @@
+package urlcache
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newResponse(header http.Header, reqHeader http.Header) *http.Response {
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if reqHeader != nil {
+		req.Header = reqHeader
+	}
+	return &http.Response{StatusCode: 200, Header: header, Request: req}
+}
+
+func TestNewURLCache(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		stored bool
+	}{
+		{"etag", http.Header{"Etag": {`"v1"`}}, true},
+		{"last-modified", http.Header{"Last-Modified": {"Fri, 18 Oct 2019 00:00:00 GMT"}}, true},
+		{"max-age only", http.Header{"Cache-Control": {"max-age=60"}}, true},
+		{"expires only", http.Header{"Expires": {"0"}}, true},
+		{"no validator", http.Header{"Content-Type": {"text/html"}}, false},
+		{"no-store", http.Header{"Etag": {`"v1"`}, "Cache-Control": {"public, no-store"}}, false},
+	}
+	for _, tt := range tests {
+		c, hash := NewURLCache(newResponse(tt.header, nil), bytes.NewBufferString("body"), time.Now())
+		if (c != nil) != tt.stored {
+			t.Errorf("%s: stored = %v, want %v", tt.name, c != nil, tt.stored)
+		}
+		if hash != "841a2d689ad86bd1611447453c22c6fc" {
+			t.Errorf("%s: hash = %s", tt.name, hash)
+		}
+	}
+}
+
+func TestFresh(t *testing.T) {
+	date := time.Date(2019, 10, 18, 12, 0, 0, 0, time.UTC)
+	httpDate := func(d time.Duration) string { return date.Add(d).Format(http.TimeFormat) }
+
+	tests := []struct {
+		name   string
+		header http.Header
+		after  time.Duration
+		shared bool
+		want   bool
+	}{
+		{"max-age", http.Header{"Cache-Control": {"max-age=60"}}, 59 * time.Second, false, true},
+		{"max-age expired", http.Header{"Cache-Control": {"max-age=60"}}, 60 * time.Second, false, false},
+		{"age is subtracted", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"50"}}, 20 * time.Second, false, false},
+		{"invalid max-age", http.Header{"Cache-Control": {"max-age=soon"}}, 0, false, false},
+		{"s-maxage for shared", http.Header{"Cache-Control": {"max-age=10, s-maxage=120"}}, time.Minute, true, true},
+		{"s-maxage ignored for private", http.Header{"Cache-Control": {"max-age=10, s-maxage=120"}}, time.Minute, false, false},
+		{"max-age over expires", http.Header{"Cache-Control": {"max-age=60"}, "Expires": {httpDate(-time.Hour)}}, 30 * time.Second, false, true},
+		{"expires", http.Header{"Expires": {httpDate(time.Hour)}}, 59 * time.Minute, false, true},
+		{"invalid expires", http.Header{"Expires": {"0"}}, 0, false, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0, false, false},
+		{"proxy-revalidate", http.Header{"Cache-Control": {"proxy-revalidate"}, "Etag": {`"v1"`}}, 0, true, false},
+		{"validator only", http.Header{"Etag": {`"v1"`}}, 0, false, false},
+	}
+	for _, tt := range tests {
+		tt.header.Set("Date", httpDate(0))
+		c := &URLCache{CacheControl: ParseCacheControl(tt.header)}
+		c.updateFreshness(tt.header, date, date)
+		if got := c.Fresh(date.Add(tt.after), tt.shared); got != tt.want {
+			t.Errorf("%s: Fresh = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	now := time.Now()
+	res := newResponse(http.Header{
+		"Etag":          {`"v1"`},
+		"Cache-Control": {"max-age=0"},
+		"Expires":       {now.Add(time.Hour).UTC().Format(http.TimeFormat)},
+	}, nil)
+	c, _ := NewURLCache(res, bytes.NewBufferString("body"), now)
+	if c == nil || c.Fresh(now, false) {
+		t.Fatalf("cache should be stored and stale: %+v", c)
+	}
+
+	// 304 の Cache-Control で鮮度が戻り、Expires と MD5 は引き継ぐ
+	n := c.Refresh(&http.Response{StatusCode: 304, Header: http.Header{
+		"Cache-Control": {"max-age=60"},
+		"Etag":          {`"v2"`},
+	}}, time.Now())
+	if n == nil || !n.Fresh(time.Now(), false) {
+		t.Fatalf("refreshed cache should be fresh: %+v", n)
+	}
+	if n.Etag != `"v2"` || n.MD5 != c.MD5 || !n.HasExpires {
+		t.Errorf("refreshed cache = etag:%s md5:%s expires:%v", n.Etag, n.MD5, n.HasExpires)
+	}
+	// 元のものは書き換えない
+	if c.Etag != `"v1"` || c.Fresh(time.Now(), false) {
+		t.Errorf("original cache is modified: %+v", c)
+	}
+
+	if n := c.Refresh(&http.Response{StatusCode: 304, Header: http.Header{"Cache-Control": {"no-store"}}}, time.Now()); n != nil {
+		t.Errorf("no-store 304 should drop the cache")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		vary      []string
+		stored    http.Header
+		requested http.Header
+		want      bool
+	}{
+		{"no vary", nil, http.Header{"Cookie": {"a=1"}}, http.Header{"Cookie": {"a=2"}}, true},
+		{"same cookie", []string{"Cookie"}, http.Header{"Cookie": {"session=1; theme=dark"}}, http.Header{"Cookie": {"session=1; theme=dark"}}, true},
+		{"different cookie", []string{"Cookie"}, http.Header{"Cookie": {"session=1"}}, http.Header{"Cookie": {"session=2"}}, false},
+		{"missing cookie", []string{"Cookie"}, http.Header{"Cookie": {"session=1"}}, http.Header{}, false},
+		{"both without header", []string{"Accept-Encoding"}, http.Header{}, http.Header{}, true},
+		{"case of vary name", []string{"accept-encoding"}, http.Header{"Accept-Encoding": {"gzip"}}, http.Header{"Accept-Encoding": {"gzip"}}, true},
+		{"vary star", []string{"*"}, http.Header{}, http.Header{}, false},
+	}
+	for _, tt := range tests {
+		h := http.Header{"Etag": {`"v1"`}}
+		for _, v := range tt.vary {
+			h.Add("Vary", v)
+		}
+		c, _ := NewURLCache(newResponse(h, tt.stored), bytes.NewBufferString("body"), time.Now())
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		req.Header = tt.requested
+		if got := c.Matches(req); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
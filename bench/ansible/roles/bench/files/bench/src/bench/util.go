@@ -3,10 +3,8 @@ package bench
 import (
 	"bytes"
 	"fmt"
-	"math/rand"
 	"strings"
 	"sync"
-	"time"
 )
 
 func assert(flag bool, msgs ...interface{}) {
@@ -27,15 +25,6 @@ func trim(s string) string {
 
 var alphabet = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890")
 
-func RandomAlphabetString(n int) string {
-	rand.Seed(time.Now().UnixNano())
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = alphabet[rand.Intn(len(alphabet))]
-	}
-	return string(b)
-}
-
 var bytesBufferPool = sync.Pool{
 	New: func() interface{} {
 		return new(bytes.Buffer)
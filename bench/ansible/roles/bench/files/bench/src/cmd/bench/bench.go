@@ -2,18 +2,21 @@ package main
 
 import (
 	"bench/counter"
+	"bench/latency"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"bench"
@@ -27,19 +30,30 @@ var (
 	loadLogs      []string
 
 	pprofPort      int = 16060
-	maxConcurrency int
+	maxConcurrency int32
+	concurrency    int32
+
+	// -rate が指定された場合は到着レートを固定して負荷をかける
+	openLoopRate        string
+	openLoopSteps       []*rateStep
+	openLoopMaxInflight int
+	openLoopMaxQueue    int
+
+	// 分散実行のコーディネータとして負荷走行を指示するエージェント
+	agentAddrs []string
+
+	// error_report に含めるエラー内容の分類ごとの件数
+	errorReportSamples = 5
 )
 
 type loadFunc func(ctx context.Context, state *bench.State) error
 
-func addLoadFunc(weight int, f loadFunc) {
-	for i := 0; i < weight; i++ {
-		loadFuncs = append(loadFuncs, f)
-	}
+func addLoadFunc(name string, weight int, f loadFunc) {
+	loadFuncMix = append(loadFuncMix, &namedLoadFunc{name, weight, f})
 }
 
-func choiceLoadFunc() loadFunc {
-	return loadFuncs[rand.Intn(len(loadFuncs))]
+func choiceLoadFunc(ctx context.Context) loadFunc {
+	return loadFuncs[bench.RandFrom(ctx).Intn(len(loadFuncs))]
 }
 
 func requestInitialize(targetHost string) error {
@@ -127,39 +141,24 @@ func preTest(ctx context.Context, state *bench.State) error {
 }
 
 func validationMain(ctx context.Context, state *bench.State) error {
-	x := rand.Perm(7)
+	checks := enabledValidationChecks
+	if len(checks) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	// 負荷走行の goroutine は 1 から番号を振るので 0 を使う
+	ctx = bench.WithWorkerRand(ctx, 0)
+	x := bench.RandFrom(ctx).Perm(len(checks))
 	for r := range x {
 		if ctx.Err() != nil {
 			return nil
 		}
 
-		var err error
+		v := checks[x[r]]
 		t := time.Now()
-
-		switch x[r] {
-		case 0:
-			err = bench.CheckNotLoggedInUser(ctx, state)
-			log.Println("CheckNotLoggedInUser", time.Since(t))
-		case 1:
-			err = bench.CheckLogin(ctx, state)
-			log.Println("CheckLogin", time.Since(t))
-		case 2:
-			err = bench.CheckAddUser(ctx, state)
-			log.Println("CheckAddUser", time.Since(t))
-		case 3:
-			err = bench.CheckLayout(ctx, state)
-			log.Println("CheckLayout", time.Since(t))
-		case 4:
-			err = bench.CheckImage(ctx, state)
-			log.Println("CheckImage", time.Since(t))
-		case 5:
-			err = bench.CheckStaticFiles(ctx, state)
-			log.Println("CheckStaticFiles", time.Since(t))
-		case 6:
-			err = bench.CheckOrder(ctx, state)
-			log.Println("CheckOrder", time.Since(t))
-
-		}
+		err := v.Func(ctx, state)
+		log.Println(v.Label, time.Since(t))
 
 		isFatalError := false
 		if cerr, ok := err.(*bench.CheckerError); ok {
@@ -179,67 +178,184 @@ func validationMain(ctx context.Context, state *bench.State) error {
 	return nil
 }
 
-func benchmarkMain(ctx context.Context, state *bench.State) {
-	maxConcurrency = 10
-	concurrency := 0
+// 負荷レベルが下がって goroutine 数が maxConcurrency を超えていたら1つ減らして true を返す
+func retireWorker() bool {
+	for {
+		c := atomic.LoadInt32(&concurrency)
+		if c <= atomic.LoadInt32(&maxConcurrency) {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&concurrency, c, c-1) {
+			return true
+		}
+	}
+}
+
+// 負荷走行を始める時の負荷レベル
+const initialLoadLevel = 10
+
+// -profile の場合は、フェーズが切り替わった時に一度に上げられるように最大の負荷レベルまでユーザを追加しておく
+// 負荷走行の時間に含めないように、負荷走行の ctx を作る前に呼ぶ
+// 戻り値は追加した後の負荷レベルの上限で、benchmarkMain の peak の初期値にする
+func preAddProfileUsers(ctx context.Context, state *bench.State) int {
+	peak := initialLoadLevel
+	pc, ok := loadController.(*profileController)
+	if !ok || !preAddUser || openLoopSteps != nil {
+		return peak
+	}
+	target := pc.profile.MaxConcurrency()
+	if target <= peak {
+		return peak
+	}
+
+	log.Println("PreAddUser for profile.", peak, "->", target)
+	for i := peak; i < target && ctx.Err() == nil; i++ {
+		if err := bench.PreAddUser(ctx, state); err == nil {
+			peak++
+		}
+	}
+	if peak < target {
+		log.Println("Cannot pre-add users for profile.", peak, "/", target)
+		addTimelineEvent(fmt.Sprintf("ユーザの追加に失敗したため負荷レベルは %d までになります", peak))
+	}
+	return peak
+}
+
+// peak はユーザを追加せずに上げられる負荷レベル (preAddProfileUsers の戻り値)
+func benchmarkMain(ctx context.Context, state *bench.State, peak int) {
+	atomic.StoreInt32(&maxConcurrency, initialLoadLevel)
 	beat := time.NewTicker(1 * time.Second)
 	defer beat.Stop()
 
+	start := time.Now()
+	// peak はこれまでで最も高い負荷レベル。これを超える分はユーザを追加してから上げる
+	// ユーザの追加は1秒に addUserPerBeat 人までにする
+	addUserPerBeat := 5
+	// 目標の負荷レベルに届かないことを記録済みか (届くようになるまで繰り返し記録しない)
+	unreachable := false
+	// 起動した goroutine の番号。goroutine ごとの乱数の seed に使う
+	workerSeq := 0
+	prevLatency := latency.GetTotal()
+	prevErrors, _ := bench.GetCheckerErrorCount()
+
 	for {
-		for i := concurrency; i < maxConcurrency; i++ {
+		// 一度に多く増やす場合でも1秒以内に起動し終わるように間隔を詰める
+		spawnInterval := 200 * time.Millisecond
+		if n := atomic.LoadInt32(&maxConcurrency) - atomic.LoadInt32(&concurrency); 5 < n {
+			spawnInterval = time.Second / time.Duration(n)
+		}
+		for i := atomic.LoadInt32(&concurrency); i < atomic.LoadInt32(&maxConcurrency); i++ {
+			workerSeq++
+			ctx := bench.WithWorkerRand(ctx, workerSeq)
 			go func() {
-				concurrency++
-				log.Println("concurrency(add):", concurrency)
+				log.Println("concurrency(add):", atomic.AddInt32(&concurrency, 1))
 				for {
 					if ctx.Err() != nil {
 						break
 					}
+					if retireWorker() {
+						log.Println("concurrency(retire):", atomic.LoadInt32(&concurrency))
+						return
+					}
 
-					err := choiceLoadFunc()(ctx, state)
+					err := choiceLoadFunc(ctx)(ctx, state)
 					if err != nil {
 						break
 					}
 				}
-				concurrency--
-				log.Println("concurrency(delete):", concurrency)
+				log.Println("concurrency(delete):", atomic.AddInt32(&concurrency, -1))
 			}()
-			time.Sleep(200 * time.Millisecond) //0.2s
+			time.Sleep(spawnInterval)
 		}
 
 		select {
 		case <-beat.C:
+			window := latency.GetTotal()
+			total := window.Clone()
+			window.Sub(prevLatency)
+			prevLatency = total
+			errors, _ := bench.GetCheckerErrorCount()
+			st := &LoadStatus{
+				Elapsed:        time.Since(start),
+				MaxConcurrency: int(atomic.LoadInt32(&maxConcurrency)),
+				Errors:         errors - prevErrors,
+				Latency:        window,
+			}
+			prevErrors = errors
+
 			if noLevelup {
 				continue
 			}
 
-			e, et := bench.GetLastCheckerError()
-			hasRecentErr := e != nil && time.Since(et) < 5*time.Second
-
-			path, st := bench.GetLastSlowPath()
-			hasRecentSlowPath := path != "" && time.Since(st) < 5*time.Second
+			if e, et := bench.GetLastCheckerError(); e != nil && time.Since(et) < 5*time.Second {
+				st.RecentError = e
+			}
+			if path, pt := bench.GetLastSlowPath(); path != "" && time.Since(pt) < 5*time.Second {
+				st.RecentSlowPath = path
+				if time.Since(pt) < time.Second {
+					st.SlowPath = path
+				}
+			}
 
+			target, reason := loadController.Next(st)
+			desired := target
+			// 目標に届かない理由
+			shortage := ""
+			// ユーザを追加しない場合は、同じユーザで並行して走らないように今いるユーザ数で止める
+			if !preAddUser {
+				if n := state.UserCount(); target > n {
+					target = n
+					shortage = fmt.Sprintf("ユーザ数 %d", n)
+				}
+			}
+			if target < 1 {
+				target = 1
+			}
 			now := time.Now().Format("01/02 15:04:05")
 
-			if hasRecentErr {
-				loadLogs = append(loadLogs, fmt.Sprintf("%v エラーが発生したため負荷レベルを上げられませんでした。%v", now, e))
-				log.Println("Cannot increase Load Level. Reason: RecentErr", e, "Before", time.Since(et))
-			} else if hasRecentSlowPath {
-				loadLogs = append(loadLogs, fmt.Sprintf("%v レスポンスが遅いため負荷レベルを上げられませんでした。%v", now, path))
-				log.Println("Cannot increase Load Level. Reason: SlowPath", path, "Before", time.Since(st))
-			} else {
+			if st.MaxConcurrency < target {
 				loadLogs = append(loadLogs, fmt.Sprintf("%v 負荷レベルが上昇しました。", now))
+				addTimelineEvent("負荷レベル上昇")
 				counter.IncKey("load-level-up")
-				log.Println("Increase Load Level.")
-				addUser := 5
-				errorUser := 0
-				for i := 0; i < addUser; i++ {
-					err := bench.PreAddUser(ctx, state)
-					if err != nil {
-						errorUser++
+				log.Println("Increase Load Level.", st.MaxConcurrency, "->", target)
+				if preAddUser && peak < target {
+					added, wanted := 0, 0
+					for i := peak; i < target && i < peak+addUserPerBeat; i++ {
+						wanted++
+						if err := bench.PreAddUser(ctx, state); err == nil {
+							added++
+						}
+					}
+					if added < wanted {
+						shortage = "ユーザの追加に失敗"
 					}
+					// 追加できたユーザの分だけ上げる。残りは次の beat 以降に追加する
+					peak += added
+					if peak < target {
+						target = peak
+					}
+				}
+			} else if target < st.MaxConcurrency {
+				loadLogs = append(loadLogs, fmt.Sprintf("%v %s", now, reason))
+				addTimelineEvent("負荷レベル低下 " + reason)
+				counter.IncKey("load-level-down")
+				log.Println("Decrease Load Level.", st.MaxConcurrency, "->", target, "Reason:", reason)
+			} else if reason != "" {
+				loadLogs = append(loadLogs, fmt.Sprintf("%v %s", now, reason))
+				addTimelineEvent("負荷レベル維持 " + reason)
+				log.Println("Cannot increase Load Level. Reason:", reason)
+			}
+			if shortage != "" && target < desired {
+				if !unreachable {
+					unreachable = true
+					loadLogs = append(loadLogs, fmt.Sprintf("%v 負荷レベル %d に届きません (%s)", now, desired, shortage))
+					addTimelineEvent(fmt.Sprintf("負荷レベル %d に届きません (%s)", desired, shortage))
+					log.Println("Cannot reach Load Level.", desired, "->", target, "Reason:", shortage)
 				}
-				maxConcurrency = maxConcurrency + addUser - errorUser
+			} else if target >= desired {
+				unreachable = false
 			}
+			atomic.StoreInt32(&maxConcurrency, int32(target))
 		case <-ctx.Done():
 			// ベンチ終了、このタイミングでエラーの収集をやめる。
 			bench.GuardCheckerError(true)
@@ -315,10 +431,18 @@ func startBenchmark(remoteAddrs []string) *BenchResult {
 
 	state := new(bench.State)
 
+	// 分散実行ではユーザを分けて最初の分を検証に使う
+	var userParts [][]*bench.AppUser
 	log.Println("State.Init()")
-	state.Init()
+	if len(agentAddrs) != 0 {
+		userParts = splitUsers(bench.DataSet.Users, len(agentAddrs)+1)
+		state.InitWithUsers(userParts[0])
+	} else {
+		state.Init()
+	}
 	log.Println("State.Init() Done")
 
+	setStatusPhase("reset")
 	log.Println("reset()")
 	err := requestInitialize(bench.GetRandomTargetHost())
 	if err != nil {
@@ -329,11 +453,13 @@ func startBenchmark(remoteAddrs []string) *BenchResult {
 	}
 	log.Println("reset() Done")
 
-	ctx, cancel := context.WithTimeout(context.Background(), benchDuration)
-	defer cancel()
+	// 負荷走行の時間 (-profile のフェーズの合計) に preTest の時間が含まれないように、別の ctx にする
+	preCtx, preCancel := context.WithTimeout(context.Background(), benchDuration)
+	defer preCancel()
 
+	setStatusPhase("pretest")
 	log.Println("preTest()")
-	err = preTest(ctx, state)
+	err = preTest(preCtx, state)
 	if err != nil {
 		result.Score = 0
 		result.Errors = getErrorsString()
@@ -349,13 +475,31 @@ func startBenchmark(remoteAddrs []string) *BenchResult {
 		return result
 	}
 
+	peak := preAddProfileUsers(preCtx, state)
+
+	// benchmarkMain はこの直後に起動するので、-profile のフェーズとこの ctx の期限は同じ時刻から数える
+	ctx, cancel := context.WithTimeout(context.Background(), benchDuration)
+	defer cancel()
+
+	setStatusPhase("load")
 	log.Println("validationMain()")
-	go benchmarkMain(ctx, state)
+	go runTimeline(ctx)
+	var waitAgents func() []*AgentRunResult
+	if len(agentAddrs) != 0 {
+		agentCtx, cancelAgents := context.WithCancel(context.Background())
+		defer cancelAgents()
+		deadline, _ := ctx.Deadline()
+		waitAgents = startAgents(agentCtx, agentAddrs, userParts[1:], remoteAddrs, deadline)
+	} else if openLoopSteps != nil {
+		go openLoopMain(ctx, state, openLoopSteps, openLoopMaxInflight, openLoopMaxQueue)
+	} else {
+		go benchmarkMain(ctx, state, peak)
+	}
 	for {
 		err = validationMain(ctx, state)
 		if ctx.Err() != nil {
 			bench.GuardCheckerError(true)
-			maxConcurrency = 0
+			atomic.StoreInt32(&maxConcurrency, 0)
 			break
 		}
 		if err != nil {
@@ -367,36 +511,31 @@ func startBenchmark(remoteAddrs []string) *BenchResult {
 	}
 
 	log.Println("validationMain() Done")
+	if waitAgents != nil {
+		log.Println("waitAgents()")
+		mergeAgentResults(waitAgents())
+		log.Println("waitAgents() Done")
+	}
+	setStatusPhase("done")
 
 	printCounterSummary()
 
-	getCount := counter.SumPrefix(`GET|/`)
-	postCount := counter.SumPrefix(`POST|/`)
-	s304Count := counter.GetKey("staticfile-304")
-	score := 1*(getCount-s304Count) + 3*postCount + s304Count/100
-
-	log.Println("get", getCount)
-	log.Println("post", postCount)
-	log.Println("s304", s304Count)
-	log.Println("score", score)
-
 	result.LoadLevel = int(counter.GetKey("load-level-up"))
+	if openLoopSteps != nil {
+		result.OpenLoop = getOpenLoopResult(openLoopRate)
+		log.Println("openloop", "arrivals", result.OpenLoop.Arrivals, "started", result.OpenLoop.Started, "dropped", result.OpenLoop.Dropped, "no_user", result.OpenLoop.NoUser)
+	}
 
 	result.Errors = getErrorsString()
 
-	requestCount := getCount + postCount + s304Count
-	errorCount := len(result.Errors)
+	errorCount, timeoutCount := bench.GetCheckerErrorCount()
 	log.Println("error", float64(errorCount))
 
-	if float64(requestCount)*0.01 < float64(errorCount) {
-		result.Pass = false
-		result.Score = 0
-		result.Message = "エラー率 1 % 以上のため計測されません。"
-	} else {
-		result.Pass = true
-		result.Score = score
-		result.Message = "ok"
-	}
+	result.Score, result.Pass, result.Message = scorer.Score(&ScoreInput{
+		Counts:   counter.GetMap(),
+		Errors:   errorCount,
+		Timeouts: timeoutCount,
+	})
 
 	return result
 }
@@ -415,8 +554,23 @@ func main() {
 		tempdir    string
 		test       bool
 		debug      bool
+		csrfCache  bool
+		cacheSkip  bool
 		nolevelup  bool
 		duration   time.Duration
+		scenarios  string
+		scoreConf  string
+		seed       int64
+		record     string
+		replay     string
+		speed      float64
+		loadCtrl   string
+		targetLat  time.Duration
+		profile    string
+		mix        string
+		agent      string
+		node       string
+		agents     string
 	)
 
 	flag.BoolVar(&workermode, "workermode", false, "workermode")
@@ -430,9 +584,37 @@ func main() {
 	flag.BoolVar(&debug, "debug", false, "add debugging info into request header")
 	flag.DurationVar(&duration, "duration", time.Minute, "benchamrk duration")
 	flag.BoolVar(&nolevelup, "nolevelup", false, "dont increase load level")
+	flag.StringVar(&scenarios, "scenarios", "", "path to scenario json file or directory (added to load functions)")
+	flag.StringVar(&scoreConf, "score-config", "", "path to score config json (default: built-in formula)")
+	flag.Int64Var(&seed, "seed", 0, "random seed (default: current time)")
+	flag.StringVar(&record, "record", "", "path to write request trace (.gz to compress)")
+	flag.StringVar(&replay, "replay", "", "path to request trace to replay instead of running scenarios")
+	flag.Float64Var(&speed, "replay-speed", 1.0, "replay pacing multiplier (2 = twice as fast as recorded)")
+	flag.StringVar(&loadCtrl, "load-controller", "ramp", fmt.Sprintf("how to change load level %v", loadControllerNames))
+	flag.StringVar(&openLoopRate, "rate", "", "start load functions at a fixed arrival rate instead of closed loop (e.g. 50/s or 10/s:30s,50/s)")
+	flag.IntVar(&openLoopMaxInflight, "max-inflight", 1000, "max concurrently running load functions, capped at the number of users (only used with -rate)")
+	flag.IntVar(&openLoopMaxQueue, "max-queue", 1000, "max arrivals waiting to start before being dropped (only used with -rate)")
+	flag.StringVar(&agent, "agent", "", "run as a load agent listening on this address (e.g. :17001)")
+	flag.StringVar(&node, "node", "", "agent node name (default: hostname and agent address)")
+	flag.StringVar(&agents, "agents", "", "comma separated agent addresses to distribute load to (coordinator mode)")
+	flag.StringVar(&mix, "mix", "", "load function weights and validation checks (e.g. read=10,post=0,check.add_user=0 or path to json)")
+	flag.StringVar(&profile, "profile", "", "path to load profile json (overrides -duration and -load-controller)")
+	flag.DurationVar(&targetLat, "target-latency", 500*time.Millisecond, "p90 latency target (only used with -load-controller=latency)")
+	flag.BoolVar(&cacheSkip, "cache-skip", true, "skip requests whose cached response is still fresh (RFC 7234)")
+	flag.BoolVar(&csrfCache, "csrf-cache", false, "reuse csrf_token within the same session instead of fetching the form page every time")
+	flag.IntVar(&pprofPort, "pprof-port", pprofPort, "port for pprof and status (0 = any free port, for running several benchmarks on one host)")
 	flag.Parse()
 
 	bench.DebugMode = debug
+	bench.CsrfTokenCacheEnabled = csrfCache
+	bench.CacheSkipEnabled = cacheSkip
+	// 0 も seed として指定できるように、指定されたかどうかは flag.Visit で見る
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			bench.SetSeed(seed)
+		}
+	})
+	log.Println("Seed", bench.GetSeed())
 	// テストデータの保存先
 	bench.DataPath = dataPath
 	// テストデータの準備
@@ -442,6 +624,39 @@ func main() {
 	noLevelup = nolevelup
 	benchDuration = duration
 
+	if scoreConf != "" {
+		c, err := loadScoreConfig(scoreConf)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scorer = &weightedScorer{c}
+	}
+
+	c, err := newLoadController(loadCtrl, targetLat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	loadController = c
+
+	if profile != "" {
+		p, err := loadProfile(profile)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		loadController = &profileController{p}
+		loadCtrl = "profile:" + p.Name
+		benchDuration = p.TotalDuration()
+	}
+	log.Println("LoadController", loadCtrl, "Duration", benchDuration)
+
+	if openLoopRate != "" {
+		openLoopSteps, err = parseRate(openLoopRate)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("OpenLoop", openLoopRate)
+	}
+
 	if workermode {
 		runWorkerMode(tempdir, portalUrl)
 		return
@@ -457,16 +672,79 @@ func main() {
 	}
 	log.Println("Remotes", remoteAddrs)
 
-	addLoadFunc(1, bench.LoadUserOperation)
-	addLoadFunc(3, bench.LoadPostOperation)
-	addLoadFunc(7, bench.LoadReadOperation)
+	addLoadFunc("user", 1, bench.LoadUserOperation)
+	addLoadFunc("post", 3, bench.LoadPostOperation)
+	addLoadFunc("read", 7, bench.LoadReadOperation)
+
+	if scenarios != "" {
+		files, err := bench.LoadScenarioFiles(scenarios)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, s := range files {
+			log.Println("Scenario", s.Name, "weight", s.Weight)
+			addLoadFunc("scenario:"+s.Name, s.Weight, s.Run)
+		}
+	}
+
+	if mix != "" {
+		c, err := parseMix(mix)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := applyMix(c); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if err := buildLoadFuncs(); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("Mix", getMixResult())
+
+	if agent != "" {
+		if node == "" {
+			hostname, _ := os.Hostname()
+			node = hostname + agent
+		}
+		runAgent(agent, node)
+		return
+	}
+	if agents != "" {
+		agentAddrs = strings.Split(agents, ",")
+		if err := pingAgents(agentAddrs); err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("Agents", agentAddrs)
+	}
 
 	bench.SetTargetHosts(remoteAddrs)
+	// エラーになったリクエストは結果の JSON と同じ場所に HAR で保存する
+	bench.EnableHAR(output != "")
 
-	result := startBenchmark(remoteAddrs)
+	var result *BenchResult
+	if replay != "" {
+		result = startReplay(replay, speed)
+	} else {
+		if record != "" {
+			err := bench.StartTraceRecording(record)
+			if err != nil {
+				log.Fatalln(err)
+			}
+		}
+		result = startBenchmark(remoteAddrs)
+		if err := bench.StopTraceRecording(); err != nil {
+			log.Println(err)
+		}
+	}
 	result.IPAddrs = remotes
 	result.JobID = jobid
+	result.Seed = bench.GetSeed()
+	result.LoadController = loadCtrl
+	result.Mix = getMixResult()
 	result.Logs = loadLogs
+	result.ErrorReport = bench.GetErrorReport(errorReportSamples)
+	result.Latency = latency.GetSummaries()
+	result.Timeline = getTimeline()
 
 	b, err := json.Marshal(result)
 	if err != nil {
@@ -481,6 +759,13 @@ func main() {
 			log.Fatalln(err)
 		}
 		log.Println("result json saved to ", output)
+
+		harPath := strings.TrimSuffix(output, filepath.Ext(output)) + ".har"
+		if err := bench.WriteHARFile(harPath); err != nil {
+			log.Println(err)
+		} else {
+			log.Println("failed requests saved to ", harPath)
+		}
 	}
 
 	log.Println("Last reset()")
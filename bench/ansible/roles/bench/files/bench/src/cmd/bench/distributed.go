@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bench"
+	"bench/counter"
+	"bench/latency"
+)
+
+// 分散実行
+//
+// エージェントは bench -agent=:17001 で起動し、コーディネータの指示で負荷走行だけを行う
+// コーディネータは bench -agents=host1:17001,host2:17001 で起動し、/reset と検証は自分で行う
+// DataSet のユーザをノード数で分け、各エージェントに同じ開始時刻を指示し、
+// 終了後にリクエスト数・エラー・レスポンスタイムを集めて1つの結果にする
+//
+// エージェントへの指示は HTTP で、負荷走行が終わるまでレスポンスを返さない
+//   GET  /agent/ping
+//   POST /agent/run  AgentRunRequest -> AgentRunResult
+
+// エージェントが負荷走行を始めるまでの猶予
+const agentStartDelay = 2 * time.Second
+
+type AgentRunRequest struct {
+	Remotes  []string      `json:"remotes"`
+	Users    []string      `json:"users"`
+	StartAt  time.Time     `json:"start_at"`
+	Duration time.Duration `json:"duration"`
+	Seed     int64         `json:"seed"`
+}
+
+type AgentRunResult struct {
+	Node     string                        `json:"node"`
+	Counts   map[string]int64              `json:"counts"`
+	Errors   []*bench.RemoteError          `json:"errors"`
+	Latency  map[string]*latency.Histogram `json:"latency"`
+	Timeline []*TimelinePoint              `json:"timeline"`
+	Logs     []string                      `json:"logs"`
+}
+
+var (
+	agentNode    string
+	agentRunning int32
+)
+
+func runAgent(addr, node string) {
+	agentNode = node
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/agent/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"node": agentNode})
+	})
+	mux.HandleFunc("/agent/run", serveAgentRun)
+
+	log.Println("Agent", node, "listening on", addr)
+	log.Fatalln(http.ListenAndServe(addr, mux))
+}
+
+func serveAgentRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := new(AgentRunRequest)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 1つのエージェントで同時に走らせる負荷走行は1つだけ
+	if !atomic.CompareAndSwapInt32(&agentRunning, 0, 1) {
+		http.Error(w, "already running", http.StatusConflict)
+		return
+	}
+	defer atomic.StoreInt32(&agentRunning, 0)
+
+	result := runAgentLoad(r.Context(), req)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Println(err)
+	}
+}
+
+func runAgentLoad(parent context.Context, req *AgentRunRequest) *AgentRunResult {
+	counter.Reset()
+	latency.Reset()
+	bench.ResetCheckerErrors()
+	resetTimeline()
+	loadLogs = nil
+
+	bench.SetSeed(req.Seed)
+	bench.SetTargetHosts(req.Remotes)
+
+	userMap := map[string]*bench.AppUser{}
+	for _, u := range bench.DataSet.Users {
+		userMap[u.Name] = u
+	}
+	var users []*bench.AppUser
+	for _, name := range req.Users {
+		if u, ok := userMap[name]; ok {
+			users = append(users, u)
+		}
+	}
+	state := new(bench.State)
+	state.InitWithUsers(users)
+
+	log.Println("Agent run", "users", len(users), "start", req.StartAt, "duration", req.Duration)
+	peak := preAddProfileUsers(parent, state)
+
+	select {
+	case <-time.After(time.Until(req.StartAt)):
+	case <-parent.Done():
+		return &AgentRunResult{Node: agentNode}
+	}
+
+	ctx, cancel := context.WithTimeout(parent, req.Duration)
+	defer cancel()
+
+	setStatusPhase("load")
+	go runTimeline(ctx)
+	if openLoopSteps != nil {
+		go openLoopMain(ctx, state, openLoopSteps, openLoopMaxInflight, openLoopMaxQueue)
+	} else {
+		go benchmarkMain(ctx, state, peak)
+	}
+	<-ctx.Done()
+	bench.GuardCheckerError(true)
+	atomic.StoreInt32(&maxConcurrency, 0)
+	setStatusPhase("done")
+
+	log.Println("Agent run Done")
+	printCounterSummary()
+
+	return &AgentRunResult{
+		Node:     agentNode,
+		Counts:   counter.GetMap(),
+		Errors:   bench.ExportCheckerErrors(),
+		Latency:  latency.GetMap(),
+		Timeline: getTimeline(),
+		Logs:     loadLogs,
+	}
+}
+
+func pingAgents(agents []string) error {
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, a := range agents {
+		res, err := client.Get("http://" + a + "/agent/ping")
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("agent %s: %s", a, res.Status)
+		}
+	}
+	return nil
+}
+
+// ユーザを n 個に分ける
+func splitUsers(users []*bench.AppUser, n int) [][]*bench.AppUser {
+	parts := make([][]*bench.AppUser, n)
+	for i, u := range users {
+		parts[i%n] = append(parts[i%n], u)
+	}
+	return parts
+}
+
+// 各エージェントに負荷走行を指示し、全ての結果を待つ関数を返す
+// users[i] をエージェント i に割り当てる
+func startAgents(ctx context.Context, agents []string, users [][]*bench.AppUser, remotes []string, deadline time.Time) func() []*AgentRunResult {
+	startAt := time.Now().Add(agentStartDelay)
+	duration := deadline.Sub(startAt)
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	var results []*AgentRunResult
+
+	for i, a := range agents {
+		req := &AgentRunRequest{
+			Remotes:  remotes,
+			StartAt:  startAt,
+			Duration: duration,
+			Seed:     bench.GetSeed() + int64(i+1),
+		}
+		for _, u := range users[i] {
+			req.Users = append(req.Users, u.Name)
+		}
+
+		wg.Add(1)
+		go func(agent string, req *AgentRunRequest) {
+			defer wg.Done()
+
+			res, err := requestAgentRun(ctx, agent, req)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				log.Println("Agent", agent, err)
+				loadLogs = append(loadLogs, fmt.Sprintf("エージェント %s で負荷走行に失敗しました。%v", agent, err))
+				return
+			}
+			results = append(results, res)
+		}(a, req)
+	}
+
+	log.Println("Agents", agents, "start", startAt, "duration", duration)
+	addTimelineEvent(fmt.Sprintf("エージェント %d 台で負荷走行開始", len(agents)))
+
+	return func() []*AgentRunResult {
+		wg.Wait()
+		return results
+	}
+}
+
+func requestAgentRun(ctx context.Context, agent string, req *AgentRunRequest) (*AgentRunResult, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hreq, err := http.NewRequest(http.MethodPost, "http://"+agent+"/agent/run", bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(hreq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	}
+
+	result := new(AgentRunResult)
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// エージェントの結果をコーディネータのカウンタ・エラー・レスポンスタイム・タイムラインに足す
+func mergeAgentResults(results []*AgentRunResult) {
+	for _, r := range results {
+		log.Println("Agent", r.Node, "errors", len(r.Errors))
+		for key, n := range r.Counts {
+			counter.AddKey(key, int(n))
+		}
+		bench.ImportCheckerErrors(r.Node, r.Errors)
+		latency.MergeMap(r.Latency)
+		mergeTimeline(r.Node, r.Timeline)
+		for _, l := range r.Logs {
+			loadLogs = append(loadLogs, r.Node+": "+l)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"bench"
+	"bench/latency"
+)
 
 // portal/job.go と同期する事
 
@@ -14,6 +19,16 @@ type BenchResult struct {
 	Errors    []string `json:"error"`
 	Logs      []string `json:"log"`
 	LoadLevel int      `json:"load_level"`
+	Seed      int64    `json:"seed"`
+
+	LoadController string     `json:"load_controller"`
+	Mix            *MixResult `json:"mix"`
+
+	ErrorReport *bench.ErrorReport          `json:"error_report"`
+	Latency     map[string]*latency.Summary `json:"latency"`
+	Timeline    []*TimelinePoint            `json:"timeline"`
+	Replay      *bench.ReplayResult         `json:"replay,omitempty"`
+	OpenLoop    *OpenLoopResult             `json:"open_loop,omitempty"`
 
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
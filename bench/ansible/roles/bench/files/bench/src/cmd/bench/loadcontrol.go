@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"bench/latency"
+)
+
+// 負荷レベル (負荷走行の goroutine 数の上限) を決めるための直近の状況
+type LoadStatus struct {
+	Elapsed        time.Duration
+	MaxConcurrency int
+
+	// 直近 5 秒以内のエラーとレスポンスが遅かったパス
+	RecentError    error
+	RecentSlowPath string
+
+	// 直前の 1 秒間のエラー数、レスポンスが遅かったパス、レスポンスタイム
+	Errors   int
+	SlowPath string
+	Latency  *latency.Histogram
+}
+
+// 1秒ごとに呼ばれ、次の負荷レベルとその理由を返す
+// 理由は負荷レベルを変えなかった場合や下げた場合にログに残す
+type LoadController interface {
+	Next(st *LoadStatus) (target int, reason string)
+}
+
+var (
+	loadController LoadController = &rampController{Step: 5}
+
+	loadControllerNames = []string{"ramp", "aimd", "latency"}
+)
+
+func newLoadController(name string, targetLatency time.Duration) (LoadController, error) {
+	switch name {
+	case "ramp":
+		return &rampController{Step: 5}, nil
+	case "aimd":
+		return &aimdController{Step: 5, Factor: 0.7, Min: 10, Cooldown: 5 * time.Second}, nil
+	case "latency":
+		return &latencyController{Target: targetLatency, Quantile: 0.9, Step: 5, Factor: 0.7, Min: 10}, nil
+	}
+	return nil, fmt.Errorf("unknown load controller %q (available: %v)", name, loadControllerNames)
+}
+
+// 本番で使った負荷の上げ方
+// 直近 5 秒以内にエラーもレスポンスの遅いリクエストもなければ Step ずつ上げる。下げることはない
+type rampController struct {
+	Step int
+}
+
+func (c *rampController) Next(st *LoadStatus) (int, string) {
+	if st.RecentError != nil {
+		return st.MaxConcurrency, fmt.Sprintf("エラーが発生したため負荷レベルを上げられませんでした。%v", st.RecentError)
+	}
+	if st.RecentSlowPath != "" {
+		return st.MaxConcurrency, fmt.Sprintf("レスポンスが遅いため負荷レベルを上げられませんでした。%v", st.RecentSlowPath)
+	}
+	return st.MaxConcurrency + c.Step, ""
+}
+
+// 直前の 1 秒にエラーかレスポンスの遅いリクエストがあれば Factor 倍に下げ、なければ Step ずつ上げる
+// 下げた後 Cooldown の間は変えない
+type aimdController struct {
+	Step     int
+	Factor   float64
+	Min      int
+	Cooldown time.Duration
+
+	decreasedAt time.Duration
+	decreased   bool
+}
+
+func (c *aimdController) decrease(st *LoadStatus) int {
+	c.decreased = true
+	c.decreasedAt = st.Elapsed
+	return decreaseLoad(st.MaxConcurrency, c.Factor, c.Min)
+}
+
+func (c *aimdController) Next(st *LoadStatus) (int, string) {
+	if c.decreased && st.Elapsed-c.decreasedAt < c.Cooldown {
+		return st.MaxConcurrency, ""
+	}
+	if st.Errors > 0 {
+		return c.decrease(st), fmt.Sprintf("エラーが %d 件発生したため負荷レベルを下げました。", st.Errors)
+	}
+	if st.SlowPath != "" {
+		return c.decrease(st), fmt.Sprintf("レスポンスが遅いため負荷レベルを下げました。%v", st.SlowPath)
+	}
+	return st.MaxConcurrency + c.Step, ""
+}
+
+// 直前の 1 秒のレスポンスタイムの Quantile 分位点が Target に収まるように調整する
+// Target を超えたら超えた割合だけ下げ、Target の 8 割未満なら Step ずつ上げる
+type latencyController struct {
+	Target   time.Duration
+	Quantile float64
+	Step     int
+	Factor   float64
+	Min      int
+}
+
+func (c *latencyController) Next(st *LoadStatus) (int, string) {
+	if st.Errors > 0 {
+		return decreaseLoad(st.MaxConcurrency, c.Factor, c.Min), fmt.Sprintf("エラーが %d 件発生したため負荷レベルを下げました。", st.Errors)
+	}
+	if st.Latency == nil || st.Latency.Count == 0 {
+		return st.MaxConcurrency, "完了したリクエストがないため負荷レベルを維持しました。"
+	}
+
+	q := st.Latency.Quantile(c.Quantile)
+	name := fmt.Sprintf("p%d", int(c.Quantile*100))
+
+	if c.Target < q {
+		// 一度に半分より下げない
+		ratio := float64(c.Target) / float64(q)
+		if ratio < 0.5 {
+			ratio = 0.5
+		}
+		return decreaseLoad(st.MaxConcurrency, ratio, c.Min), fmt.Sprintf("%s %v が目標 %v を超えたため負荷レベルを下げました。", name, q, c.Target)
+	}
+	if q < c.Target*8/10 {
+		return st.MaxConcurrency + c.Step, ""
+	}
+	return st.MaxConcurrency, fmt.Sprintf("%s %v が目標 %v に近いため負荷レベルを維持しました。", name, q, c.Target)
+}
+
+func decreaseLoad(cur int, factor float64, min int) int {
+	n := int(float64(cur) * factor)
+	if n >= cur {
+		n = cur - 1
+	}
+	if n < min {
+		n = min
+	}
+	if cur < n {
+		n = cur
+	}
+	return n
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDecreaseLoad(t *testing.T) {
+	tests := []struct {
+		cur    int
+		factor float64
+		min    int
+		want   int
+	}{
+		{100, 0.7, 10, 70},
+		{15, 0.5, 10, 10},
+		{10, 0.7, 10, 10},
+		// 既に下限より低い場合は上げない
+		{5, 0.7, 10, 5},
+		// 係数が 1 に近くても 1 は下げる
+		{20, 0.99, 1, 19},
+		{2, 0.9, 1, 1},
+		{1, 0.5, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := decreaseLoad(tt.cur, tt.factor, tt.min); got != tt.want {
+			t.Errorf("decreaseLoad(%d, %v, %d) = %d, want %d", tt.cur, tt.factor, tt.min, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bench"
+)
+
+// -mix で負荷走行の関数の重みと validationMain で行うチェックを変更する
+//
+// "read=10,post=0,user=0,check.add_user=0,pre_add_user=0" のように並べるか、
+// 同じ内容の JSON ファイルを指定する
+//
+//	{
+//	  "load": {"read": 10, "post": 0, "user": 0, "scenario:post-bulletin": 2},
+//	  "validation": {"add_user": false},
+//	  "pre_add_user": false
+//	}
+//
+// シナリオファイルの関数は "scenario:" にシナリオの name を付けた名前で指定する
+// 重み 0 の関数と false にしたチェックは実行しない
+// pre_add_user を false にすると負荷レベルを上げる時にユーザを追加しない (読み込みのみの走行用)
+// その場合、負荷レベルはその時点のユーザ数までしか上げない
+type MixConfig struct {
+	Load       map[string]int  `json:"load"`
+	Validation map[string]bool `json:"validation"`
+	PreAddUser *bool           `json:"pre_add_user"`
+}
+
+// 実際に使った重みとチェック
+type MixResult struct {
+	Load       map[string]int `json:"load"`
+	Validation []string       `json:"validation"`
+	PreAddUser bool           `json:"pre_add_user"`
+}
+
+type namedLoadFunc struct {
+	Name   string
+	Weight int
+	Func   loadFunc
+}
+
+type validationCheck struct {
+	Name string
+	// ログに出す名前
+	Label string
+	Func  loadFunc
+}
+
+var (
+	loadFuncMix []*namedLoadFunc
+
+	validationChecks = []*validationCheck{
+		{"not_logged_in", "CheckNotLoggedInUser", bench.CheckNotLoggedInUser},
+		{"login", "CheckLogin", bench.CheckLogin},
+		{"add_user", "CheckAddUser", bench.CheckAddUser},
+		{"layout", "CheckLayout", bench.CheckLayout},
+		{"image", "CheckImage", bench.CheckImage},
+		{"static_files", "CheckStaticFiles", bench.CheckStaticFiles},
+		{"order", "CheckOrder", bench.CheckOrder},
+	}
+	enabledValidationChecks = validationChecks
+
+	preAddUser = true
+)
+
+func parseMix(s string) (*MixConfig, error) {
+	c := &MixConfig{Load: map[string]int{}, Validation: map[string]bool{}}
+
+	if strings.HasSuffix(s, ".json") {
+		b, err := ioutil.ReadFile(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("%s: %v", s, err)
+		}
+		return c, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid mix %q: must be name=weight", kv)
+		}
+		name, val := kv[:i], kv[i+1:]
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid mix %q", kv)
+		}
+
+		switch {
+		case name == "pre_add_user":
+			b := n != 0
+			c.PreAddUser = &b
+		case strings.HasPrefix(name, "check."):
+			c.Validation[name[len("check."):]] = n != 0
+		default:
+			c.Load[name] = n
+		}
+	}
+	return c, nil
+}
+
+func applyMix(c *MixConfig) error {
+	for name, w := range c.Load {
+		found := false
+		for _, f := range loadFuncMix {
+			if f.Name == name {
+				f.Weight = w
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown load function %q", name)
+		}
+	}
+
+	for name := range c.Validation {
+		found := false
+		for _, v := range validationChecks {
+			if v.Name == name {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown validation check %q", name)
+		}
+	}
+	enabledValidationChecks = nil
+	for _, v := range validationChecks {
+		if enabled, ok := c.Validation[v.Name]; !ok || enabled {
+			enabledValidationChecks = append(enabledValidationChecks, v)
+		}
+	}
+
+	if c.PreAddUser != nil {
+		preAddUser = *c.PreAddUser
+	}
+	return nil
+}
+
+// 重みの分だけ loadFuncs に並べる
+func buildLoadFuncs() error {
+	loadFuncs = nil
+	for _, f := range loadFuncMix {
+		for i := 0; i < f.Weight; i++ {
+			loadFuncs = append(loadFuncs, f.Func)
+		}
+	}
+	if len(loadFuncs) == 0 {
+		return fmt.Errorf("no load function is enabled")
+	}
+	return nil
+}
+
+func getMixResult() *MixResult {
+	r := &MixResult{
+		Load:       map[string]int{},
+		Validation: []string{},
+		PreAddUser: preAddUser,
+	}
+	for _, f := range loadFuncMix {
+		r.Load[f.Name] = f.Weight
+	}
+	for _, v := range enabledValidationChecks {
+		r.Validation = append(r.Validation, v.Name)
+	}
+	sort.Strings(r.Validation)
+	return r
+}
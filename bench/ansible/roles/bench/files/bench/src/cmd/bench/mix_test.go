@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseMix(t *testing.T) {
+	f, tr := false, true
+	tests := []struct {
+		name    string
+		mix     string
+		want    *MixConfig
+		wantErr bool
+	}{
+		{"weights", "read=10, post=0,scenario:post-bulletin=2", &MixConfig{
+			Load:       map[string]int{"read": 10, "post": 0, "scenario:post-bulletin": 2},
+			Validation: map[string]bool{},
+		}, false},
+		{"checks", "check.add_user=0,check.layout=1", &MixConfig{
+			Load:       map[string]int{},
+			Validation: map[string]bool{"add_user": false, "layout": true},
+		}, false},
+		{"pre_add_user off", "pre_add_user=0", &MixConfig{
+			Load:       map[string]int{},
+			Validation: map[string]bool{},
+			PreAddUser: &f,
+		}, false},
+		{"pre_add_user on", "pre_add_user=1", &MixConfig{
+			Load:       map[string]int{},
+			Validation: map[string]bool{},
+			PreAddUser: &tr,
+		}, false},
+		{"no weight", "read", nil, true},
+		{"not a number", "read=many", nil, true},
+		{"negative", "read=-1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMix(tt.mix)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parseMix(%q) should fail", tt.name, tt.mix)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseMix(%q) = %+v, want %+v", tt.name, tt.mix, got, tt.want)
+		}
+	}
+}
+
+func TestParseMixJSON(t *testing.T) {
+	path := writeTempFile(t, "mix.json", `{
+		"load": {"read": 10, "scenario:post-bulletin": 2},
+		"validation": {"add_user": false},
+		"pre_add_user": false
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	got, err := parseMix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := false
+	want := &MixConfig{
+		Load:       map[string]int{"read": 10, "scenario:post-bulletin": 2},
+		Validation: map[string]bool{"add_user": false},
+		PreAddUser: &f,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMix = %+v, want %+v", got, want)
+	}
+}
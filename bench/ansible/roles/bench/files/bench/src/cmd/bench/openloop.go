@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bench"
+	"bench/latency"
+)
+
+// 一定の到着レートで負荷走行の関数を開始するモード (-rate)
+// レスポンスが遅くなっても開始のペースは落とさない
+//
+// -rate の形式は "50/s" または "10/s:30s,50/s:30s,100/s" のように段階を並べたもの
+// 最後の段階は時間を省略でき、負荷走行の終了まで続く
+
+type rateStep struct {
+	Rate     float64
+	Duration time.Duration
+}
+
+type OpenLoopResult struct {
+	Rate string `json:"rate"`
+	// 到着した数、開始した数、待ち行列が溢れて開始できなかった数、
+	// 空いているユーザがいなくて開始できなかった数
+	Arrivals int64 `json:"arrivals"`
+	Started  int64 `json:"started"`
+	Dropped  int64 `json:"dropped"`
+	NoUser   int64 `json:"no_user"`
+	// ユーザ数で制限した後の同時実行数の上限
+	MaxInflight int `json:"max_inflight"`
+	// 到着してから開始するまでの待ち時間
+	QueueDelay *latency.Summary `json:"queue_delay"`
+}
+
+var (
+	openLoopMtx        sync.Mutex
+	openLoopQueueDelay = latency.NewHistogram()
+	openLoopArrivals   int64
+	openLoopStarted    int64
+	openLoopDropped    int64
+	openLoopNoUser     int64
+	openLoopInflight   int
+)
+
+// 負荷走行の関数はユーザを1人使うので、同時実行数はユーザ数までにする
+// validationMain と PreAddUser が使う分を残しておく
+const openLoopReservedUsers = 2
+
+func parseRate(s string) ([]*rateStep, error) {
+	var steps []*rateStep
+	parts := strings.Split(s, ",")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		var rate, dur string
+		if j := strings.Index(part, ":"); j >= 0 {
+			rate, dur = part[:j], part[j+1:]
+		} else {
+			rate = part
+		}
+
+		if !strings.HasSuffix(rate, "/s") {
+			return nil, fmt.Errorf("invalid rate %q: must be N/s", part)
+		}
+		r, err := strconv.ParseFloat(strings.TrimSuffix(rate, "/s"), 64)
+		if err != nil || math.IsNaN(r) || math.IsInf(r, 0) || r < 0 {
+			return nil, fmt.Errorf("invalid rate %q", part)
+		}
+		// 到着の間隔が 1ns 未満になると到着のループが止まらなくなる
+		if r > 0 && float64(time.Second)/r < 1 {
+			return nil, fmt.Errorf("rate %q is too large", part)
+		}
+
+		step := &rateStep{Rate: r}
+		if dur != "" {
+			step.Duration, err = time.ParseDuration(dur)
+			if err != nil || step.Duration <= 0 {
+				return nil, fmt.Errorf("invalid duration %q", part)
+			}
+		} else if i != len(parts)-1 {
+			return nil, fmt.Errorf("duration is required except for the last step: %q", part)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// 到着した時刻を積んだ待ち行列を maxInflight 個の goroutine で処理する
+// 待ち行列が maxQueue を超えた到着は捨てて dropped に数える
+func openLoopMain(ctx context.Context, state *bench.State, steps []*rateStep, maxInflight, maxQueue int) {
+	if n := state.UserCount() - openLoopReservedUsers; n < maxInflight {
+		if n < 1 {
+			n = 1
+		}
+		log.Println("OpenLoop max inflight", maxInflight, "->", n, "(limited by users)")
+		maxInflight = n
+	}
+	openLoopMtx.Lock()
+	openLoopInflight = maxInflight
+	openLoopMtx.Unlock()
+
+	queue := make(chan time.Time, maxQueue)
+	atomic.StoreInt32(&maxConcurrency, int32(maxInflight))
+
+	for i := 0; i < maxInflight; i++ {
+		ctx := bench.WithWorkerRand(ctx, i+1)
+		go func() {
+			for {
+				var arrived time.Time
+				select {
+				case arrived = <-queue:
+				case <-ctx.Done():
+					return
+				}
+
+				// 関数を呼んでもユーザがいなければ何もせずに終わるので、開始した数には入れない
+				if state.FreeUserCount() == 0 {
+					atomic.AddInt64(&openLoopNoUser, 1)
+					continue
+				}
+
+				openLoopMtx.Lock()
+				openLoopQueueDelay.Add(time.Since(arrived))
+				openLoopMtx.Unlock()
+				atomic.AddInt64(&openLoopStarted, 1)
+
+				atomic.AddInt32(&concurrency, 1)
+				// 閉ループと違いエラーでも goroutine は止めない
+				choiceLoadFunc(ctx)(ctx, state)
+				atomic.AddInt32(&concurrency, -1)
+			}
+		}()
+	}
+
+	for _, step := range steps {
+		log.Println("OpenLoop rate", step.Rate, "/s")
+		addTimelineEvent(fmt.Sprintf("到着レート %v/s", step.Rate))
+
+		var end <-chan time.Time
+		if step.Duration > 0 {
+			end = time.After(step.Duration)
+		}
+
+		if step.Rate == 0 {
+			select {
+			case <-end:
+				continue
+			case <-ctx.Done():
+				bench.GuardCheckerError(true)
+				return
+			}
+		}
+
+		interval := time.Duration(float64(time.Second) / step.Rate)
+		next := time.Now()
+	arrivals:
+		for {
+			select {
+			case <-end:
+				break arrivals
+			case <-ctx.Done():
+				// ベンチ終了、このタイミングでエラーの収集をやめる。
+				bench.GuardCheckerError(true)
+				return
+			case <-time.After(time.Until(next)):
+			}
+
+			atomic.AddInt64(&openLoopArrivals, 1)
+			select {
+			case queue <- next:
+			default:
+				atomic.AddInt64(&openLoopDropped, 1)
+			}
+			next = next.Add(interval)
+		}
+	}
+
+	<-ctx.Done()
+	bench.GuardCheckerError(true)
+}
+
+func getOpenLoopResult(rate string) *OpenLoopResult {
+	openLoopMtx.Lock()
+	delay := openLoopQueueDelay.Summary()
+	inflight := openLoopInflight
+	openLoopMtx.Unlock()
+
+	return &OpenLoopResult{
+		Rate:        rate,
+		Arrivals:    atomic.LoadInt64(&openLoopArrivals),
+		Started:     atomic.LoadInt64(&openLoopStarted),
+		Dropped:     atomic.LoadInt64(&openLoopDropped),
+		NoUser:      atomic.LoadInt64(&openLoopNoUser),
+		MaxInflight: inflight,
+		QueueDelay:  delay,
+	}
+}
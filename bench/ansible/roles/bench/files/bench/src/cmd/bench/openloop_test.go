@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []rateStep
+		wantErr bool
+	}{
+		{"50/s", []rateStep{{50, 0}}, false},
+		{"10/s:30s, 50/s", []rateStep{{10, 30 * time.Second}, {50, 0}}, false},
+		{"0/s:5s,2.5/s:1m", []rateStep{{0, 5 * time.Second}, {2.5, time.Minute}}, false},
+		{"50", nil, true},
+		{"-1/s", nil, true},
+		{"10/s,20/s", nil, true},
+		{"10/s:0s", nil, true},
+		{"10/s:abc", nil, true},
+		{"NaN/s", nil, true},
+		{"Inf/s", nil, true},
+		{"+Inf/s:10s,1/s", nil, true},
+		{"1e10/s", nil, true},
+		{"1e9/s", []rateStep{{1e9, 0}}, false},
+	}
+	for _, tt := range tests {
+		steps, err := parseRate(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseRate(%q) should fail", tt.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRate(%q): %v", tt.in, err)
+			continue
+		}
+		if len(steps) != len(tt.want) {
+			t.Errorf("parseRate(%q) = %d steps, want %d", tt.in, len(steps), len(tt.want))
+			continue
+		}
+		for i, s := range steps {
+			if *s != tt.want[i] {
+				t.Errorf("parseRate(%q)[%d] = %+v, want %+v", tt.in, i, *s, tt.want[i])
+			}
+		}
+	}
+}
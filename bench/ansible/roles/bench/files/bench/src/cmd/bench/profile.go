@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// -profile で読み込む負荷のかけ方の定義
+// フェーズを順に実行し、合計時間が -duration の代わりになる
+//
+//	{
+//	  "name": "spike",
+//	  "phases": [
+//	    {"name": "warm-up", "duration": "30s", "concurrency": 10},
+//	    {"name": "ramp", "duration": "2m", "from": 10, "to": 100},
+//	    {"name": "spike", "duration": "30s", "concurrency": 300},
+//	    {"name": "hold", "duration": "10m", "concurrency": 100},
+//	    {"name": "cool-down", "duration": "1m", "from": 100, "to": 10}
+//	  ]
+//	}
+//
+// from, to を指定したフェーズはその間を線形に変化させる
+type LoadProfile struct {
+	Name   string       `json:"name"`
+	Phases []*LoadPhase `json:"phases"`
+}
+
+type LoadPhase struct {
+	Name        string `json:"name"`
+	Duration    string `json:"duration"`
+	Concurrency int    `json:"concurrency"`
+	From        int    `json:"from"`
+	To          int    `json:"to"`
+
+	duration time.Duration
+}
+
+var (
+	loadPhaseMtx sync.Mutex
+	loadPhase    string
+)
+
+func setLoadPhase(name string) {
+	loadPhaseMtx.Lock()
+	loadPhase = name
+	loadPhaseMtx.Unlock()
+}
+
+func getLoadPhase() string {
+	loadPhaseMtx.Lock()
+	defer loadPhaseMtx.Unlock()
+
+	return loadPhase
+}
+
+func loadProfile(path string) (*LoadProfile, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(LoadProfile)
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(p.Phases) == 0 {
+		return nil, fmt.Errorf("%s: phases is empty", path)
+	}
+	for i, ph := range p.Phases {
+		if ph.Name == "" {
+			ph.Name = fmt.Sprintf("phase%d", i+1)
+		}
+		ph.duration, err = time.ParseDuration(ph.Duration)
+		if err != nil || ph.duration <= 0 {
+			return nil, fmt.Errorf("%s: phases[%d]: invalid duration %q", path, i, ph.Duration)
+		}
+		if ph.Concurrency <= 0 && (ph.From <= 0 || ph.To <= 0) {
+			return nil, fmt.Errorf("%s: phases[%d]: concurrency or from/to is required", path, i)
+		}
+	}
+	return p, nil
+}
+
+func (p *LoadProfile) TotalDuration() time.Duration {
+	var d time.Duration
+	for _, ph := range p.Phases {
+		d += ph.duration
+	}
+	return d
+}
+
+// 全てのフェーズの中で最も高い負荷レベル
+func (p *LoadProfile) MaxConcurrency() int {
+	max := 0
+	for _, ph := range p.Phases {
+		for _, n := range []int{ph.Concurrency, ph.From, ph.To} {
+			if max < n {
+				max = n
+			}
+		}
+	}
+	return max
+}
+
+// elapsed 時点のフェーズとその中での進み具合 (0.0 - 1.0)
+// 全てのフェーズが終わった後は最後のフェーズの終了時点とする
+func (p *LoadProfile) phaseAt(elapsed time.Duration) (*LoadPhase, float64) {
+	for _, ph := range p.Phases {
+		if elapsed < ph.duration {
+			return ph, float64(elapsed) / float64(ph.duration)
+		}
+		elapsed -= ph.duration
+	}
+	return p.Phases[len(p.Phases)-1], 1
+}
+
+func (ph *LoadPhase) concurrencyAt(progress float64) int {
+	if ph.Concurrency > 0 {
+		return ph.Concurrency
+	}
+	// 下げるフェーズでも To に届くように四捨五入する
+	return ph.From + int(math.Floor(float64(ph.To-ph.From)*progress+0.5))
+}
+
+// プロファイルに従って負荷レベルを決める
+// エラーやレスポンスの遅延があっても変えない
+type profileController struct {
+	profile *LoadProfile
+}
+
+func (c *profileController) Next(st *LoadStatus) (int, string) {
+	ph, progress := c.profile.phaseAt(st.Elapsed)
+	if getLoadPhase() != ph.Name {
+		log.Println("LoadPhase", ph.Name)
+		addTimelineEvent("フェーズ開始 " + ph.Name)
+		setLoadPhase(ph.Name)
+	}
+	target := ph.concurrencyAt(progress)
+	if target == st.MaxConcurrency {
+		return target, ""
+	}
+	return target, "フェーズ " + ph.Name
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "bench")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		total   time.Duration
+		wantErr bool
+	}{
+		{"valid", `{"phases": [{"duration": "30s", "concurrency": 10}, {"name": "ramp", "duration": "1m", "from": 10, "to": 100}]}`, 90 * time.Second, false},
+		{"empty", `{"phases": []}`, 0, true},
+		{"bad duration", `{"phases": [{"duration": "soon", "concurrency": 10}]}`, 0, true},
+		{"zero duration", `{"phases": [{"duration": "0s", "concurrency": 10}]}`, 0, true},
+		{"no concurrency", `{"phases": [{"duration": "10s", "from": 10}]}`, 0, true},
+		{"broken json", `{"phases": [`, 0, true},
+	}
+	for _, tt := range tests {
+		path := writeTempFile(t, "profile.json", tt.json)
+		p, err := loadProfile(path)
+		os.RemoveAll(filepath.Dir(path))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: loadProfile should fail", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if p.TotalDuration() != tt.total {
+			t.Errorf("%s: TotalDuration = %v, want %v", tt.name, p.TotalDuration(), tt.total)
+		}
+		if p.Phases[0].Name != "phase1" {
+			t.Errorf("%s: default phase name = %q, want phase1", tt.name, p.Phases[0].Name)
+		}
+	}
+}
+
+func TestProfileConcurrencyAt(t *testing.T) {
+	p := &LoadProfile{Phases: []*LoadPhase{
+		{Name: "warm-up", Concurrency: 10, duration: 10 * time.Second},
+		{Name: "ramp", From: 10, To: 110, duration: 100 * time.Second},
+		{Name: "cool-down", From: 110, To: 10, duration: 10 * time.Second},
+	}}
+	tests := []struct {
+		elapsed time.Duration
+		phase   string
+		want    int
+	}{
+		{0, "warm-up", 10},
+		{9 * time.Second, "warm-up", 10},
+		{10 * time.Second, "ramp", 10},
+		{60 * time.Second, "ramp", 60},
+		{110 * time.Second, "cool-down", 110},
+		{115 * time.Second, "cool-down", 60},
+		// 全てのフェーズが終わった後は最後のフェーズの終了時点
+		{time.Hour, "cool-down", 10},
+	}
+	for _, tt := range tests {
+		ph, progress := p.phaseAt(tt.elapsed)
+		if ph.Name != tt.phase {
+			t.Errorf("phaseAt(%v) = %s, want %s", tt.elapsed, ph.Name, tt.phase)
+			continue
+		}
+		if got := ph.concurrencyAt(progress); got != tt.want {
+			t.Errorf("concurrencyAt(%v) = %d, want %d", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestProfileMaxConcurrency(t *testing.T) {
+	p := &LoadProfile{Phases: []*LoadPhase{
+		{Name: "ramp", From: 10, To: 50},
+		{Name: "spike", Concurrency: 200},
+		{Name: "cool-down", From: 50, To: 10},
+	}}
+	if got := p.MaxConcurrency(); got != 200 {
+		t.Errorf("MaxConcurrency = %d, want 200", got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"bench"
+)
+
+// -record で保存したトレースを -remotes に対して再生する
+func startReplay(tracePath string, speed float64) *BenchResult {
+	result := new(BenchResult)
+	result.StartTime = time.Now()
+	defer func() {
+		result.EndTime = time.Now()
+	}()
+
+	records, err := bench.LoadTraceFile(tracePath)
+	if err != nil {
+		result.Message = fmt.Sprint("トレースファイルの読み込みに失敗しました。", err)
+		return result
+	}
+	log.Println("Replay", len(records), "requests from", tracePath, "speed", speed)
+
+	log.Println("reset()")
+	err = requestInitialize(bench.GetRandomTargetHost())
+	if err != nil {
+		result.Message = fmt.Sprint("/reset へのリクエストに失敗しました。", err)
+		return result
+	}
+	log.Println("reset() Done")
+
+	setStatusPhase("replay")
+	r := bench.Replay(context.Background(), records, speed)
+	setStatusPhase("done")
+	log.Println("Replay Done", r.Requests, "requests", r.Failures, "failures", r.ServerErrors, "server errors", r.Mismatches, "mismatches")
+
+	result.Replay = r
+	result.Pass = r.Failures == 0 && r.ServerErrors == 0 && r.Mismatches == 0
+	switch {
+	case result.Pass:
+		result.Message = fmt.Sprintf("%d 件のリクエストを再生しました。", r.Requests)
+	case r.Failures != 0:
+		result.Message = fmt.Sprintf("%d 件のリクエストを再生しましたが、%d 件のリクエストに失敗しました。", r.Requests, r.Failures)
+	case r.ServerErrors != 0:
+		result.Message = fmt.Sprintf("%d 件のリクエストを再生しましたが、%d 件でサーバエラーが発生しました。", r.Requests, r.ServerErrors)
+	default:
+		result.Message = fmt.Sprintf("%d 件のリクエストを再生しましたが、%d 件のステータスコードが記録時と異なりました。", r.Requests, r.Mismatches)
+	}
+	return result
+}
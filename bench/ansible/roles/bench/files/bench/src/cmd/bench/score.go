@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"strings"
+
+	"bench"
+)
+
+type ScoreInput struct {
+	Counts   map[string]int64
+	Errors   int
+	Timeouts int
+}
+
+type Scorer interface {
+	Score(in *ScoreInput) (score int64, pass bool, message string)
+}
+
+var scorer Scorer = defaultScorer{}
+
+// 本番で使ったスコア計算
+// 1*(GET-304) + 3*POST + 304/100 で、エラー率 1% 以上は 0 点
+// キャッシュの鮮度があってリクエストを省略したもの (SKIP|) は 304 と同じに数える
+type defaultScorer struct{}
+
+func (defaultScorer) Score(in *ScoreInput) (int64, bool, string) {
+	var getCount, postCount int64
+	for key, count := range in.Counts {
+		if strings.HasPrefix(key, "GET|/") {
+			getCount += count
+		} else if strings.HasPrefix(key, "POST|/") {
+			postCount += count
+		}
+	}
+	s304Count := in.Counts["staticfile-304"]
+	skipCount := skippedCount(in.Counts)
+	score := 1*(getCount-s304Count) + 3*postCount + (s304Count+skipCount)/100
+
+	log.Println("get", getCount)
+	log.Println("post", postCount)
+	log.Println("s304", s304Count)
+	log.Println("skip", skipCount)
+	log.Println("score", score)
+
+	requestCount := getCount + postCount + s304Count
+	if float64(requestCount)*0.01 < float64(in.Errors) {
+		return 0, false, "エラー率 1 % 以上のため計測されません。"
+	}
+	return score, true, "ok"
+}
+
+// -score-config で読み込むスコア計算の設定
+// 指定されなかった項目は defaultScorer と同じ値になる
+type ScoreConfig struct {
+	GetWeight  float64 `json:"get_weight"`
+	PostWeight float64 `json:"post_weight"`
+	// "POST|/users/add" や "GET|/bulletins/view/*" のように bench.NormalizePath したパスで指定する
+	EndpointWeights map[string]float64 `json:"endpoint_weights"`
+	// 静的ファイルの 304 は get_weight の代わりにこの重みで数える
+	NotModifiedWeight float64 `json:"not_modified_weight"`
+	// キャッシュの鮮度があってリクエストを省略したもの 1 件ごとの重み
+	SkippedWeight float64 `json:"skipped_weight"`
+	// タイムアウト1件ごとに引く点数
+	TimeoutPenalty float64 `json:"timeout_penalty"`
+	// エラー数がリクエスト数のこの割合を超えたら 0 点
+	FailErrorRate float64 `json:"fail_error_rate"`
+}
+
+func defaultScoreConfig() *ScoreConfig {
+	return &ScoreConfig{
+		GetWeight:         1,
+		PostWeight:        3,
+		EndpointWeights:   map[string]float64{},
+		NotModifiedWeight: 0.01,
+		SkippedWeight:     0.01,
+		TimeoutPenalty:    0,
+		FailErrorRate:     0.01,
+	}
+}
+
+func loadScoreConfig(path string) (*ScoreConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := defaultScoreConfig()
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+type weightedScorer struct {
+	config *ScoreConfig
+}
+
+func (s *weightedScorer) weight(key string) float64 {
+	i := strings.Index(key, "|")
+	method, path := key[:i], key[i+1:]
+
+	if w, ok := s.config.EndpointWeights[method+"|"+bench.NormalizePath(path)]; ok {
+		return w
+	}
+	if method == "POST" {
+		return s.config.PostWeight
+	}
+	return s.config.GetWeight
+}
+
+func (s *weightedScorer) Score(in *ScoreInput) (int64, bool, string) {
+	var total float64
+	var requestCount int64
+	for key, count := range in.Counts {
+		if strings.HasPrefix(key, "GET|/") || strings.HasPrefix(key, "POST|/") {
+			total += s.weight(key) * float64(count)
+			requestCount += count
+		}
+	}
+	// 304 は GET として数えているので、そのパスの重みを引いて not_modified_weight で数え直す
+	// パスごとの件数がない分 (古いエージェントなど) は get_weight で引く
+	s304Count := in.Counts["staticfile-304"]
+	rest := s304Count
+	for key, count := range in.Counts {
+		if strings.HasPrefix(key, notModifiedKeyPrefix) {
+			total -= float64(count) * s.weight("GET|"+strings.TrimPrefix(key, notModifiedKeyPrefix))
+			rest -= count
+		}
+	}
+	if rest > 0 {
+		total -= float64(rest) * s.config.GetWeight
+	}
+	skipCount := skippedCount(in.Counts)
+	// defaultScorer の 304/100 と同じく、重みの小さいものは合計してから切り捨てる
+	// (0.01 * 700 などが浮動小数点の誤差で 7 未満にならないように少し足す)
+	total += math.Floor(float64(s304Count)*s.config.NotModifiedWeight + float64(skipCount)*s.config.SkippedWeight + 1e-9)
+	total -= float64(in.Timeouts) * s.config.TimeoutPenalty
+	if total < 0 {
+		total = 0
+	}
+	score := int64(total)
+
+	log.Println("requests", requestCount)
+	log.Println("s304", s304Count)
+	log.Println("skip", skipCount)
+	log.Println("timeouts", in.Timeouts)
+	log.Println("score", score)
+
+	// defaultScorer と同じく、エラー率の分母には 304 をもう一度足す
+	requestCount += s304Count
+	if float64(requestCount)*s.config.FailErrorRate < float64(in.Errors) {
+		return 0, false, fmt.Sprintf("エラー率 %v %% 以上のため計測されません。", s.config.FailErrorRate*100)
+	}
+	return score, true, "ok"
+}
+
+// 静的ファイルの 304 のパスごとの件数 (staticfile-304|/static/css/main.css など)
+const notModifiedKeyPrefix = "staticfile-304|"
+
+// キャッシュでリクエストを省略した件数
+func skippedCount(counts map[string]int64) int64 {
+	var n int64
+	for key, count := range counts {
+		if strings.HasPrefix(key, "SKIP|") {
+			n += count
+		}
+	}
+	return n
+}
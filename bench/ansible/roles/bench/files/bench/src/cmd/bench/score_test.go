@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// 既定の設定の weightedScorer は defaultScorer と同じ結果になる
+func TestWeightedScorerMatchesDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		in   ScoreInput
+	}{
+		{"empty", ScoreInput{Counts: map[string]int64{}}},
+		{"get and post", ScoreInput{Counts: map[string]int64{
+			"GET|/":            120,
+			"GET|/bulletins/3": 40,
+			"POST|/login":      33,
+		}}},
+		{"static 304", ScoreInput{Counts: map[string]int64{
+			"GET|/":                                100,
+			"GET|/static/css/main.css":             750,
+			"GET|/static/icons/abe.png":            30,
+			"staticfile-304":                       699,
+			"staticfile-304|/static/css/main.css":  690,
+			"staticfile-304|/static/icons/abe.png": 9,
+			"POST|/login":                          10,
+		}}},
+		{"304 without per-path counts", ScoreInput{Counts: map[string]int64{
+			"GET|/static/css/main.css": 300,
+			"staticfile-304":           300,
+		}}},
+		{"skipped", ScoreInput{Counts: map[string]int64{
+			"GET|/":                  10,
+			"staticfile-304":         0,
+			"SKIP|/static/css/a.css": 150,
+			"SKIP|/static/icons/b":   51,
+		}}},
+		{"error rate boundary", ScoreInput{
+			Counts: map[string]int64{"GET|/": 150, "staticfile-304": 50, "GET|/static/css/main.css": 50},
+			Errors: 2,
+		}},
+		{"error rate over", ScoreInput{
+			Counts: map[string]int64{"GET|/": 150, "staticfile-304": 50, "GET|/static/css/main.css": 50},
+			Errors: 3,
+		}},
+	}
+
+	weighted := &weightedScorer{defaultScoreConfig()}
+	for _, tt := range tests {
+		wantScore, wantPass, _ := defaultScorer{}.Score(&tt.in)
+		gotScore, gotPass, _ := weighted.Score(&tt.in)
+		if gotScore != wantScore || gotPass != wantPass {
+			t.Errorf("%s: weightedScorer = (%d, %v), defaultScorer = (%d, %v)", tt.name, gotScore, gotPass, wantScore, wantPass)
+		}
+	}
+}
+
+func TestWeightedScorerEndpointWeights(t *testing.T) {
+	c := defaultScoreConfig()
+	c.EndpointWeights = map[string]float64{
+		"GET|/static/css/main.css": 2,
+		"POST|/bulletins/*/edit":   10,
+	}
+	in := &ScoreInput{Counts: map[string]int64{
+		"GET|/":                               5,
+		"GET|/static/css/main.css":            10,
+		"staticfile-304":                      4,
+		"staticfile-304|/static/css/main.css": 4,
+		"POST|/bulletins/12/edit":             1,
+	}}
+	// 5*1 + 10*2 - 4*2 + 10*1 + floor(4*0.01)
+	if score, _, _ := (&weightedScorer{c}).Score(in); score != 27 {
+		t.Errorf("score = %d, want 27", score)
+	}
+}
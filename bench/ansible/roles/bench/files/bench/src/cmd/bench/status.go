@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bench"
+	"bench/counter"
+)
+
+// pprof と同じポートで負荷走行中の状況を返す
+//   GET /status        現在の状況 (JSON)
+//   GET /status/stream 1秒ごとの状況 (Server-Sent Events)
+
+const statusRecentErrors = 10
+
+var (
+	statusMtx   sync.Mutex
+	statusStart time.Time
+	statusPhase = "init"
+)
+
+type Status struct {
+	Phase          string           `json:"phase"`
+	Elapsed        float64          `json:"elapsed_sec"`
+	LoadLevel      int64            `json:"load_level"`
+	MaxConcurrency int32            `json:"max_concurrency"`
+	Concurrency    int32            `json:"concurrency"`
+	Counts         map[string]int64 `json:"counts"`
+	ErrorCount     int              `json:"error_count"`
+	RecentErrors   []string         `json:"recent_errors"`
+	LastSlowPath   string           `json:"last_slow_path"`
+	LastSlowTime   *time.Time       `json:"last_slow_time"`
+}
+
+func init() {
+	http.HandleFunc("/status", serveStatus)
+	http.HandleFunc("/status/stream", serveStatusStream)
+}
+
+func setStatusPhase(phase string) {
+	statusMtx.Lock()
+	defer statusMtx.Unlock()
+
+	if statusStart.IsZero() {
+		statusStart = time.Now()
+	}
+	statusPhase = phase
+}
+
+func getStatus() *Status {
+	statusMtx.Lock()
+	st := &Status{Phase: statusPhase}
+	if !statusStart.IsZero() {
+		st.Elapsed = time.Since(statusStart).Seconds()
+	}
+	statusMtx.Unlock()
+
+	st.LoadLevel = counter.GetKey("load-level-up")
+	st.MaxConcurrency = atomic.LoadInt32(&maxConcurrency)
+	st.Concurrency = atomic.LoadInt32(&concurrency)
+	st.Counts = counter.GetMap()
+
+	errs := bench.GetCheckerErrors()
+	st.ErrorCount = len(errs)
+	st.RecentErrors = []string{}
+	if len(errs) > statusRecentErrors {
+		errs = errs[len(errs)-statusRecentErrors:]
+	}
+	for _, e := range errs {
+		st.RecentErrors = append(st.RecentErrors, e.Error())
+	}
+
+	path, t := bench.GetLastSlowPath()
+	if path != "" {
+		st.LastSlowPath = path
+		st.LastSlowTime = &t
+	}
+	return st
+}
+
+func serveStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(getStatus()); err != nil {
+		log.Println(err)
+	}
+}
+
+func serveStatusStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+
+	beat := time.NewTicker(1 * time.Second)
+	defer beat.Stop()
+
+	for {
+		b, err := json.Marshal(getStatus())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", b); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-beat.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bench"
+	"bench/counter"
+)
+
+// 負荷走行中の1秒ごとの状況
+type TimelinePoint struct {
+	Second         int              `json:"second"`
+	Time           time.Time        `json:"time"`
+	Phase          string           `json:"phase,omitempty"`
+	Requests       map[string]int64 `json:"requests"`
+	Errors         int              `json:"errors"`
+	Timeouts       int              `json:"timeouts"`
+	MaxConcurrency int              `json:"max_concurrency"`
+	Concurrency    int              `json:"concurrency"`
+	Goroutines     int              `json:"goroutines"`
+	Events         []string         `json:"events,omitempty"`
+}
+
+var (
+	timelineMtx    sync.Mutex
+	timeline       []*TimelinePoint
+	timelineEvents []string
+
+	timelineMethods = []string{"GET", "POST"}
+)
+
+// 次の時点の events に記録する
+func addTimelineEvent(msg string) {
+	timelineMtx.Lock()
+	timelineEvents = append(timelineEvents, msg)
+	timelineMtx.Unlock()
+}
+
+func getTimeline() []*TimelinePoint {
+	timelineMtx.Lock()
+	defer timelineMtx.Unlock()
+
+	return append([]*TimelinePoint(nil), timeline...)
+}
+
+func runTimeline(ctx context.Context) {
+	beat := time.NewTicker(1 * time.Second)
+	defer beat.Stop()
+
+	prevRequests := map[string]int64{}
+	for _, m := range timelineMethods {
+		prevRequests[m] = counter.SumPrefix(m + "|/")
+	}
+	prevErrors, prevTimeouts := bench.GetCheckerErrorCount()
+
+	for i := 1; ; i++ {
+		select {
+		case <-beat.C:
+		case <-ctx.Done():
+			return
+		}
+
+		p := &TimelinePoint{
+			Second:         i,
+			Time:           time.Now(),
+			Phase:          getLoadPhase(),
+			Requests:       map[string]int64{},
+			MaxConcurrency: int(atomic.LoadInt32(&maxConcurrency)),
+			Concurrency:    int(atomic.LoadInt32(&concurrency)),
+			Goroutines:     runtime.NumGoroutine(),
+		}
+
+		for _, m := range timelineMethods {
+			n := counter.SumPrefix(m + "|/")
+			p.Requests[m] = n - prevRequests[m]
+			prevRequests[m] = n
+		}
+
+		errors, timeouts := bench.GetCheckerErrorCount()
+		p.Errors = errors - prevErrors
+		p.Timeouts = timeouts - prevTimeouts
+		prevErrors, prevTimeouts = errors, timeouts
+
+		timelineMtx.Lock()
+		p.Events = timelineEvents
+		timelineEvents = nil
+		timeline = append(timeline, p)
+		timelineMtx.Unlock()
+	}
+}
+
+func resetTimeline() {
+	timelineMtx.Lock()
+	timeline = nil
+	timelineEvents = nil
+	timelineMtx.Unlock()
+}
+
+// エージェントのタイムラインを同じ時刻の点に足す
+func mergeTimeline(node string, points []*TimelinePoint) {
+	timelineMtx.Lock()
+	defer timelineMtx.Unlock()
+
+	if len(timeline) == 0 {
+		return
+	}
+	base := timeline[0].Time
+	for _, p := range points {
+		i := int((p.Time.Sub(base) + time.Second/2) / time.Second)
+		if i < 0 || len(timeline) <= i {
+			continue
+		}
+		t := timeline[i]
+		for m, n := range p.Requests {
+			t.Requests[m] += n
+		}
+		t.Errors += p.Errors
+		t.Timeouts += p.Timeouts
+		t.MaxConcurrency += p.MaxConcurrency
+		t.Concurrency += p.Concurrency
+		for _, e := range p.Events {
+			t.Events = append(t.Events, node+": "+e)
+		}
+	}
+}
@@ -157,6 +157,14 @@ func runWorkerMode(tempDir, portalUrl string) {
 		return nil
 	}
 
+	// ベンチマークを開始できなかったジョブは中断として報告し、ポータルで実行中のまま残らないようにする
+	abortJob := func(job *Job, err error) {
+		log.Println(err)
+		if err := postResult(job, "", "", true); err != nil {
+			log.Println(err)
+		}
+	}
+
 	for {
 		job := getJobLoop()
 		name := fmt.Sprintf("isucon7q-benchresult-%d-%d.json", time.Now().Unix(), job.ID)
@@ -173,20 +181,23 @@ func runWorkerMode(tempDir, portalUrl string) {
 
 		stdout, err := cmd.StdoutPipe()
 		if err != nil {
-			log.Println(err)
+			cancel()
+			abortJob(job, err)
 			continue
 		}
 
 		stderr, err := cmd.StderrPipe()
 		if err != nil {
-			log.Println(err)
+			cancel()
+			abortJob(job, err)
 			continue
 		}
 
 		log.Println("Start benchmark args:", cmd.Args)
 		err = cmd.Start()
 		if err != nil {
-			log.Println(err)
+			cancel()
+			abortJob(job, err)
 			continue
 		}
 
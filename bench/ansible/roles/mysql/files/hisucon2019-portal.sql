@@ -13,5 +13,71 @@ CREATE TABLE bench (
     result          json            NOT NULL,
     created_at      datetime(6)     NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
     resultfile      VARCHAR(100),
+    node            VARCHAR(64)     NOT NULL DEFAULT '',
     PRIMARY KEY (id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+DROP TABLE IF EXISTS job;
+
+-- status: queued, running, done, failed, aborted
+-- queue: 積んだ redis のキュー (myqueue か、ノード専用の myqueue:<node>)
+CREATE TABLE job (
+    id              int(11)         NOT NULL AUTO_INCREMENT,
+    team            VARCHAR(64)     NOT NULL,
+    ipaddress       VARCHAR(64)     NOT NULL,
+    status          VARCHAR(16)     NOT NULL DEFAULT 'queued',
+    queue           VARCHAR(80)     NOT NULL DEFAULT '',
+    node            VARCHAR(64)     NOT NULL DEFAULT '',
+    bench_id        int(11),
+    created_at      datetime(6)     NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    started_at      datetime(6),
+    finished_at     datetime(6),
+    PRIMARY KEY (id),
+    KEY idx_team (team, ipaddress, status),
+    KEY idx_status (status, id),
+    KEY idx_queue (queue, status, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+DROP TABLE IF EXISTS team_ipaddress;
+DROP TABLE IF EXISTS team;
+
+-- チームの登録は /srv/webapp で go run *.go team add <team> <ipaddress> を実行する
+CREATE TABLE team (
+    id              int(11)         NOT NULL AUTO_INCREMENT,
+    name            VARCHAR(64)     NOT NULL,
+    password_hash   VARCHAR(100)    NOT NULL,
+    api_token_hash  CHAR(64)        NOT NULL,
+    bench_node      VARCHAR(64)     NOT NULL DEFAULT '',
+    created_at      datetime(6)     NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (id),
+    UNIQUE KEY uniq_name (name),
+    UNIQUE KEY uniq_api_token_hash (api_token_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 1つの IP アドレスは1チームにのみ登録できる
+CREATE TABLE team_ipaddress (
+    team_id         int(11)         NOT NULL,
+    ipaddress       VARCHAR(64)     NOT NULL,
+    PRIMARY KEY (ipaddress),
+    KEY idx_team_id (team_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+DROP TABLE IF EXISTS setting;
+
+-- ポータルの設定。go run *.go limit <name> <value> で変更する
+CREATE TABLE setting (
+    name            VARCHAR(64)     NOT NULL,
+    value           VARCHAR(255)    NOT NULL,
+    PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+DROP TABLE IF EXISTS bench_node;
+
+-- ベンチマークを実行するノード。ワーカーが起動時と定期的に登録する
+CREATE TABLE bench_node (
+    name            VARCHAR(64)     NOT NULL,
+    drain           tinyint(1)      NOT NULL DEFAULT 0,
+    concurrency     int(11)         NOT NULL DEFAULT 1,
+    last_seen       datetime(6)     NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// チームの認証
+//
+// HTML のページはログインしたチームのセッション (署名付き Cookie) で、
+// /api/ の JSON API は Authorization: Bearer <token> で認証する
+// チームが操作できるのは team_ipaddress に登録した IP アドレスのみ
+//
+// チームの登録は管理者がコマンドで行う
+//   cd /srv/webapp && go run *.go team add <team> <ipaddress>[,<ipaddress>...]
+//   cd /srv/webapp && go run *.go team password <team>
+//   cd /srv/webapp && go run *.go team token <team>
+//   cd /srv/webapp && go run *.go team pin <team> <node>
+//   cd /srv/webapp && go run *.go team unpin <team>
+
+const (
+	sessionCookieName = "hisucon_session"
+	sessionLifetime   = 12 * time.Hour
+
+	// 署名の鍵。未設定の場合は起動ごとに生成するので、再起動するとログインし直しになる
+	sessionSecretEnv = "PORTAL_SESSION_SECRET"
+
+	contextTeamKey = "team"
+)
+
+type Team struct {
+	ID           int       `gorm:"column:id"`
+	Name         string    `gorm:"column:name"`
+	PasswordHash string    `gorm:"column:password_hash"`
+	APITokenHash string    `gorm:"column:api_token_hash"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+
+	// 空でなければこのノードでのみベンチマークを実行する
+	BenchNode string `gorm:"column:bench_node"`
+
+	Ipaddresses []string `gorm:"-"`
+}
+
+func (t *Team) TableName() string {
+	return "team"
+}
+
+func (t *Team) AllowIPaddress(ipaddress string) bool {
+	for _, ip := range t.Ipaddresses {
+		if ip == ipaddress {
+			return true
+		}
+	}
+	return false
+}
+
+type TeamIPaddress struct {
+	TeamID    int    `gorm:"column:team_id"`
+	Ipaddress string `gorm:"column:ipaddress"`
+}
+
+func (t *TeamIPaddress) TableName() string {
+	return "team_ipaddress"
+}
+
+var sessionSecret []byte
+
+func initSessionSecret() {
+	if s := os.Getenv(sessionSecretEnv); s != "" {
+		sessionSecret = []byte(s)
+		return
+	}
+	log.Println(sessionSecretEnv, "is not set. sessions will be lost on restart.")
+	sessionSecret = make([]byte, 32)
+	if _, err := rand.Read(sessionSecret); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func findTeam(db *gorm.DB, where string, args ...interface{}) (*Team, error) {
+	team := new(Team)
+	res := db.Where(where, args...).First(team)
+	if res.RecordNotFound() {
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	var ips []TeamIPaddress
+	if err := db.Where("team_id = ?", team.ID).Order("ipaddress").Find(&ips).Error; err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		team.Ipaddresses = append(team.Ipaddresses, ip.Ipaddress)
+	}
+	return team, nil
+}
+
+// セッションの値は base64(チーム ID:有効期限) . HMAC-SHA256
+func signSession(teamID int, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", teamID, expires.Unix())))
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifySession(value string) (int, bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return 0, false
+	}
+	payload, sig := value[:i], value[i+1:]
+
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(payload))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return 0, false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return 0, false
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return 0, false
+	}
+	teamID, err1 := strconv.Atoi(parts[0])
+	expires, err2 := strconv.ParseInt(parts[1], 10, 64)
+	if err1 != nil || err2 != nil || time.Now().Unix() > expires {
+		return 0, false
+	}
+	return teamID, true
+}
+
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func sessionTeam(db *gorm.DB, c *gin.Context) (*Team, error) {
+	cookie, err := c.Cookie(sessionCookieName)
+	if err != nil {
+		return nil, nil
+	}
+	teamID, ok := verifySession(cookie)
+	if !ok {
+		return nil, nil
+	}
+	return findTeam(db, "id = ?", teamID)
+}
+
+func bearerTeam(db *gorm.DB, c *gin.Context) (*Team, error) {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, nil
+	}
+	token := strings.TrimSpace(auth[len("Bearer "):])
+	if token == "" {
+		return nil, nil
+	}
+	return findTeam(db, "api_token_hash = ?", hashToken(token))
+}
+
+// HTML のページ用。ログインしていなければログイン画面に移動する
+func requireLogin(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team, err := sessionTeam(db, c)
+		if err != nil {
+			fmt.Println("session error.", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if team == nil {
+			c.Redirect(http.StatusFound, "/login?next="+c.Request.URL.Path)
+			c.Abort()
+			return
+		}
+		c.Set(contextTeamKey, team)
+	}
+}
+
+// ページから呼ぶ Ajax 用。ログインしていなければ 401
+// 他のサイトからのリクエストで実行されないよう、GET 以外は X-Requested-With を必須にする
+func requireSession(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team, err := sessionTeam(db, c)
+		if err != nil {
+			fmt.Println("session error.", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, string("処理に失敗しました。"))
+			return
+		}
+		if team == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, string("ログインしてください。"))
+			return
+		}
+		if c.Request.Method != http.MethodGet && c.GetHeader("X-Requested-With") != "XMLHttpRequest" {
+			c.AbortWithStatusJSON(http.StatusForbidden, string("不正なリクエストです。"))
+			return
+		}
+		c.Set(contextTeamKey, team)
+	}
+}
+
+// JSON API 用。トークンが正しくなければ 401
+func requireToken(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		team, err := bearerTeam(db, c)
+		if err != nil {
+			fmt.Println("token error.", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+			return
+		}
+		if team == nil {
+			c.Header("WWW-Authenticate", `Bearer realm="hisucon2019-portal"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.Set(contextTeamKey, team)
+	}
+}
+
+func currentTeam(c *gin.Context) *Team {
+	return c.MustGet(contextTeamKey).(*Team)
+}
+
+func loginRoutes(router *gin.Engine, db *gorm.DB) {
+	router.GET("/login", func(c *gin.Context) {
+		c.HTML(http.StatusOK, "login.tmpl", gin.H{"next": c.Query("next")})
+	})
+
+	router.POST("/login", func(c *gin.Context) {
+		name := c.PostForm("team")
+		next := c.PostForm("next")
+
+		team, err := findTeam(db, "name = ?", name)
+		if err != nil {
+			fmt.Println("login error.", err)
+			c.HTML(http.StatusInternalServerError, "error.tmpl", gin.H{"message": "処理に失敗しました。"})
+			return
+		}
+		if team == nil || bcrypt.CompareHashAndPassword([]byte(team.PasswordHash), []byte(c.PostForm("password"))) != nil {
+			c.HTML(http.StatusUnauthorized, "login.tmpl", gin.H{
+				"next":    next,
+				"team":    name,
+				"message": "チーム名またはパスワードが違います。",
+			})
+			return
+		}
+
+		setSessionCookie(c, signSession(team.ID, time.Now().Add(sessionLifetime)), int(sessionLifetime/time.Second))
+
+		// 他のサイトへのリダイレクトに使われないよう、自サイトのパスのみ受け付ける
+		if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+			next = "/"
+		}
+		c.Redirect(http.StatusFound, next)
+	})
+
+	router.POST("/logout", func(c *gin.Context) {
+		setSessionCookie(c, "", -1)
+		c.Redirect(http.StatusFound, "/login")
+	})
+
+	// ログイン後の入口。登録した IP アドレスが1つならそのページに移動する
+	router.GET("/", requireLogin(db), func(c *gin.Context) {
+		team := currentTeam(c)
+		if len(team.Ipaddresses) == 1 {
+			c.Redirect(http.StatusFound, "/top/"+team.Name+"/"+team.Ipaddresses[0])
+			return
+		}
+		c.HTML(http.StatusOK, "team.tmpl", gin.H{"team": team})
+	})
+}
+
+// 管理用のコマンド
+func runTeamCommand(db *gorm.DB, args []string) error {
+	usage := fmt.Errorf("usage: team add <team> <ipaddress>[,<ipaddress>...] | team password <team> | team token <team> | team pin <team> <node> | team unpin <team>")
+	if len(args) < 2 {
+		return usage
+	}
+	name := args[1]
+
+	switch args[0] {
+	case "add":
+		if len(args) != 3 {
+			return usage
+		}
+		password := randomToken(8)
+		token := randomToken(24)
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+
+		tx := db.Begin()
+		team := &Team{Name: name, PasswordHash: string(hash), APITokenHash: hashToken(token), CreatedAt: time.Now()}
+		if err := tx.Create(team).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, ip := range strings.Split(args[2], ",") {
+			ip = strings.TrimSpace(ip)
+			if !checkIPaddressFormat(ip) {
+				tx.Rollback()
+				return fmt.Errorf("%s は不正な値です。", ip)
+			}
+			if err := tx.Create(&TeamIPaddress{TeamID: team.ID, Ipaddress: ip}).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+		fmt.Println("team:    ", name)
+		fmt.Println("password:", password)
+		fmt.Println("token:   ", token)
+
+	case "password":
+		password := randomToken(8)
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		res := db.Model(&Team{}).Where("name = ?", name).Update("password_hash", string(hash))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("team %s not found", name)
+		}
+		fmt.Println("password:", password)
+
+	case "token":
+		token := randomToken(24)
+		res := db.Model(&Team{}).Where("name = ?", name).Update("api_token_hash", hashToken(token))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("team %s not found", name)
+		}
+		fmt.Println("token:", token)
+
+	case "pin", "unpin":
+		node := ""
+		if args[0] == "pin" {
+			if len(args) != 3 {
+				return usage
+			}
+			node = args[2]
+		}
+		team, err := findTeam(db, "name = ?", name)
+		if err != nil {
+			return err
+		}
+		if team == nil {
+			return fmt.Errorf("team %s not found", name)
+		}
+		if err := db.Model(&Team{}).Where("id = ?", team.ID).Update("bench_node", node).Error; err != nil {
+			return err
+		}
+
+	default:
+		return usage
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySession(t *testing.T) {
+	saved := sessionSecret
+	defer func() { sessionSecret = saved }()
+	sessionSecret = []byte("test-secret")
+	now := time.Now()
+	valid := signSession(42, now.Add(time.Hour))
+
+	// 署名は正しいが中身を書き換えたもの
+	forged := base64.RawURLEncoding.EncodeToString([]byte("1:"+strings.Repeat("9", 10))) + valid[strings.LastIndex(valid, "."):]
+
+	tests := []struct {
+		name   string
+		value  string
+		teamID int
+		ok     bool
+	}{
+		{"valid", valid, 42, true},
+		{"expired", signSession(42, now.Add(-time.Second)), 0, false},
+		{"no signature", valid[:strings.LastIndex(valid, ".")], 0, false},
+		{"tampered signature", valid + "x", 0, false},
+		{"forged payload", forged, 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		teamID, ok := verifySession(tt.value)
+		if teamID != tt.teamID || ok != tt.ok {
+			t.Errorf("%s: verifySession = (%d, %v), want (%d, %v)", tt.name, teamID, ok, tt.teamID, tt.ok)
+		}
+	}
+
+	// 別の鍵で署名したものは通らない
+	sessionSecret = []byte("other-secret")
+	if _, ok := verifySession(valid); ok {
+		t.Errorf("session signed with another secret is accepted")
+	}
+}
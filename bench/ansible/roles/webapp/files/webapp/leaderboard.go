@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 全チームの順位表
+//
+// 順位はチームの最高スコア (成功した結果のみ) の降順で、同点は先に達成したチームを上にする
+// 順位の変動は rankChangeWindow 前の順位と比べる
+//
+// 競技終了前の一定時間は順位表を凍結し、凍結後の結果を反映しない (各チームのページには表示する)
+//   PORTAL_CONTEST_END     競技終了時刻 (例: 2019-10-18T18:00:00+09:00)
+//   PORTAL_FREEZE_MINUTES  競技終了の何分前から凍結するか。0 または未設定なら凍結しない
+
+const (
+	contestEndEnv    = "PORTAL_CONTEST_END"
+	freezeMinutesEnv = "PORTAL_FREEZE_MINUTES"
+
+	rankChangeWindow = 30 * time.Minute
+)
+
+type LeaderboardEntry struct {
+	Rank       int       `json:"rank"`
+	Team       string    `json:"team"`
+	BestScore  int       `json:"best_score"`
+	BestAt     time.Time `json:"best_at"`
+	LatestPass bool      `json:"latest_pass"`
+	// 最新の結果のスコア。失敗した場合も記録されたスコアを出す
+	LatestScore int       `json:"latest_score"`
+	LatestAt    time.Time `json:"latest_at"`
+	Attempts    int       `json:"attempts"`
+	// 前回の順位 - 今の順位。上がったら正、前回順位がなければ New
+	RankChange int  `json:"rank_change"`
+	New        bool `json:"new"`
+}
+
+// 下がった順位の数 (表示用)
+func (e *LeaderboardEntry) RankDown() int {
+	return -e.RankChange
+}
+
+type Leaderboard struct {
+	Entries []*LeaderboardEntry `json:"entries"`
+	// 凍結中の場合は凍結した時刻
+	FrozenAt  *time.Time `json:"frozen_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// グラフに出す1回分の結果
+type ScorePoint struct {
+	Time  time.Time `json:"t"`
+	Score int       `json:"y"`
+	Pass  bool      `json:"pass"`
+}
+
+var (
+	contestEnd    *time.Time
+	freezeMinutes int
+)
+
+func initLeaderboardConfig() {
+	if s := os.Getenv(contestEndEnv); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			log.Fatalln(contestEndEnv, err)
+		}
+		contestEnd = &t
+	}
+	if s := os.Getenv(freezeMinutesEnv); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			log.Fatalln(freezeMinutesEnv, "must be a non-negative integer:", s)
+		}
+		freezeMinutes = n
+	}
+}
+
+// 凍結している場合は凍結した時刻を返す
+func freezeTime(now time.Time) *time.Time {
+	if contestEnd == nil || freezeMinutes == 0 {
+		return nil
+	}
+	t := contestEnd.Add(-time.Duration(freezeMinutes) * time.Minute)
+	if now.Before(t) {
+		return nil
+	}
+	return &t
+}
+
+type scoredBench struct {
+	Team      string
+	CreatedAt time.Time
+	Results
+}
+
+func loadScoredBench(db *gorm.DB, until time.Time) ([]*scoredBench, error) {
+	var bench []Bench
+	err := db.Select("id, team, result, created_at").Where("created_at < ?", until).Order("created_at").Find(&bench).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*scoredBench
+	for _, b := range bench {
+		sb := &scoredBench{Team: b.Team, CreatedAt: b.Created_at}
+		if err := json.Unmarshal([]byte(b.Result), &sb.Results); err != nil {
+			log.Println("invalid result.", b.Id, err)
+			continue
+		}
+		list = append(list, sb)
+	}
+	return list, nil
+}
+
+// until より前の結果で順位を計算する。list は created_at の昇順
+func rankEntries(list []*scoredBench, until time.Time) []*LeaderboardEntry {
+	byTeam := map[string]*LeaderboardEntry{}
+	var entries []*LeaderboardEntry
+	for _, b := range list {
+		if !b.CreatedAt.Before(until) {
+			break
+		}
+		e, ok := byTeam[b.Team]
+		if !ok {
+			e = &LeaderboardEntry{Team: b.Team}
+			byTeam[b.Team] = e
+			entries = append(entries, e)
+		}
+		e.Attempts++
+		e.LatestPass = b.Result
+		e.LatestScore = b.Score
+		e.LatestAt = b.CreatedAt
+		if b.Result && (b.Score > e.BestScore || e.BestAt.IsZero()) {
+			e.BestScore = b.Score
+			e.BestAt = b.CreatedAt
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.BestScore != b.BestScore {
+			return a.BestScore > b.BestScore
+		}
+		// 一度も成功していないチームは下に
+		if a.BestAt.IsZero() != b.BestAt.IsZero() {
+			return !a.BestAt.IsZero()
+		}
+		return a.BestAt.Before(b.BestAt)
+	})
+	for i, e := range entries {
+		e.Rank = i + 1
+	}
+	return entries
+}
+
+func buildLeaderboard(db *gorm.DB, now time.Time) (*Leaderboard, error) {
+	board := &Leaderboard{UpdatedAt: now}
+	until := now
+	if t := freezeTime(now); t != nil {
+		board.FrozenAt = t
+		until = *t
+	}
+
+	list, err := loadScoredBench(db, until)
+	if err != nil {
+		return nil, err
+	}
+
+	board.Entries = rankEntries(list, until)
+	prev := map[string]int{}
+	for _, e := range rankEntries(list, until.Add(-rankChangeWindow)) {
+		prev[e.Team] = e.Rank
+	}
+	for _, e := range board.Entries {
+		if r, ok := prev[e.Team]; ok {
+			e.RankChange = r - e.Rank
+		} else {
+			e.New = true
+		}
+	}
+	if board.Entries == nil {
+		board.Entries = []*LeaderboardEntry{}
+	}
+	return board, nil
+}
+
+// チームのページのグラフ用。bench, results は findResults の結果 (新しい順)
+func scorePoints(bench []Bench, results []Results) []*ScorePoint {
+	points := []*ScorePoint{}
+	for i := len(bench) - 1; i >= 0; i-- {
+		points = append(points, &ScorePoint{
+			Time:  bench[i].Created_at,
+			Score: results[i].Score,
+			Pass:  results[i].Result,
+		})
+	}
+	return points
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRankEntries(t *testing.T) {
+	base := time.Date(2019, 10, 18, 10, 0, 0, 0, time.UTC)
+	at := func(min int) time.Time { return base.Add(time.Duration(min) * time.Minute) }
+	pass := func(team string, min, score int) *scoredBench {
+		return &scoredBench{Team: team, CreatedAt: at(min), Results: Results{Result: true, Score: score}}
+	}
+	fail := func(team string, min, score int) *scoredBench {
+		return &scoredBench{Team: team, CreatedAt: at(min), Results: Results{Result: false, Score: score}}
+	}
+
+	type rank struct {
+		team       string
+		best       int
+		latestPass bool
+		attempts   int
+	}
+	tests := []struct {
+		name  string
+		list  []*scoredBench
+		until time.Time
+		want  []rank
+	}{
+		{"empty", nil, at(60), nil},
+		{"by best score", []*scoredBench{
+			pass("a", 1, 100),
+			pass("b", 2, 300),
+			pass("a", 3, 200),
+		}, at(60), []rank{{"b", 300, true, 1}, {"a", 200, true, 2}}},
+		{"failed result is not best", []*scoredBench{
+			pass("a", 1, 100),
+			fail("a", 2, 500),
+			pass("b", 3, 200),
+		}, at(60), []rank{{"b", 200, true, 1}, {"a", 100, false, 2}}},
+		{"tie goes to earlier", []*scoredBench{
+			pass("a", 1, 100),
+			pass("b", 2, 200),
+			pass("a", 3, 200),
+		}, at(60), []rank{{"b", 200, true, 1}, {"a", 200, true, 2}}},
+		{"never passed is last", []*scoredBench{
+			fail("a", 1, 0),
+			pass("b", 2, 0),
+		}, at(60), []rank{{"b", 0, true, 1}, {"a", 0, false, 1}}},
+		{"until excludes later results", []*scoredBench{
+			pass("a", 1, 100),
+			pass("b", 2, 50),
+			pass("b", 30, 300),
+		}, at(30), []rank{{"a", 100, true, 1}, {"b", 50, true, 1}}},
+	}
+	for _, tt := range tests {
+		got := rankEntries(tt.list, tt.until)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d entries, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			e := got[i]
+			if e.Rank != i+1 || e.Team != w.team || e.BestScore != w.best || e.LatestPass != w.latestPass || e.Attempts != w.attempts {
+				t.Errorf("%s: entries[%d] = {rank:%d team:%s best:%d latest_pass:%v attempts:%d}, want {rank:%d team:%s best:%d latest_pass:%v attempts:%d}",
+					tt.name, i, e.Rank, e.Team, e.BestScore, e.LatestPass, e.Attempts, i+1, w.team, w.best, w.latestPass, w.attempts)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ベンチマーク実行の制限
+//
+// キューに追加する前に確認し、超えている場合は理由を返す
+//   max_active_jobs_per_team  チームあたりの実行待ち・実行中のジョブ数の上限 (止まった実行中のジョブは数えない)
+//   submit_cooldown_seconds   チームが前回追加してから次に追加できるまでの秒数
+//   max_queued_jobs           全チームの実行待ちのジョブ数の上限
+// いずれも 0 なら制限しない
+//
+// 値は setting テーブルにあり、競技中でも再起動せずに変更できる
+//   cd /srv/webapp && go run *.go limit
+//   cd /srv/webapp && go run *.go limit <name> <value>
+//
+// 止まったままのジョブはチームを指定して中断 (aborted) にできる
+//   cd /srv/webapp && go run *.go job reset <team>
+
+type Setting struct {
+	Name  string `gorm:"column:name"`
+	Value string `gorm:"column:value"`
+}
+
+func (t *Setting) TableName() string {
+	return "setting"
+}
+
+var defaultSubmitLimits = map[string]int{
+	"max_active_jobs_per_team": 1,
+	"submit_cooldown_seconds":  60,
+	"max_queued_jobs":          0,
+}
+
+type SubmitLimits struct {
+	MaxActiveJobsPerTeam int
+	Cooldown             time.Duration
+	MaxQueuedJobs        int
+}
+
+// 制限を超えた時の理由
+type LimitError struct {
+	Status     int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// 確認とキューへの追加の間に他のリクエストが割り込まないようにする
+var submitMtx sync.Mutex
+
+func loadSubmitLimits(db *gorm.DB) (*SubmitLimits, error) {
+	values := map[string]int{}
+	for name, v := range defaultSubmitLimits {
+		values[name] = v
+	}
+
+	var settings []Setting
+	if err := db.Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		if _, ok := values[s.Name]; !ok {
+			continue
+		}
+		n, err := strconv.Atoi(s.Value)
+		if err != nil {
+			return nil, fmt.Errorf("setting %s: %v", s.Name, err)
+		}
+		values[s.Name] = n
+	}
+
+	return &SubmitLimits{
+		MaxActiveJobsPerTeam: values["max_active_jobs_per_team"],
+		Cooldown:             time.Duration(values["submit_cooldown_seconds"]) * time.Second,
+		MaxQueuedJobs:        values["max_queued_jobs"],
+	}, nil
+}
+
+// 制限を超えていれば *LimitError を返す
+func checkSubmitLimits(db *gorm.DB, team *Team, now time.Time) (*LimitError, error) {
+	limits, err := loadSubmitLimits(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*Job
+	if err := db.Where("team = ? AND status IN (?)", team.Name, []string{JobQueued, JobRunning}).Find(&active).Error; err != nil {
+		return nil, err
+	}
+
+	var last *Job
+	if limits.Cooldown > 0 {
+		last = new(Job)
+		res := db.Where("team = ?", team.Name).Order("id DESC").First(last)
+		if res.Error != nil && !res.RecordNotFound() {
+			return nil, res.Error
+		}
+		if res.RecordNotFound() {
+			last = nil
+		}
+	}
+
+	var queued int
+	if limits.MaxQueuedJobs > 0 {
+		if err := db.Model(&Job{}).Where("status = ?", JobQueued).Count(&queued).Error; err != nil {
+			return nil, err
+		}
+	}
+	return submitLimitError(limits, active, last, queued, now), nil
+}
+
+// active はチームの実行待ち・実行中のジョブ、last はチームが最後に追加したジョブ (なければ nil)、
+// queued は全体の実行待ちのジョブ数
+func submitLimitError(limits *SubmitLimits, active []*Job, last *Job, queued int, now time.Time) *LimitError {
+	n := 0
+	for _, j := range active {
+		if !isStaleJob(j, now) {
+			n++
+		}
+	}
+	if limits.MaxActiveJobsPerTeam > 0 && n >= limits.MaxActiveJobsPerTeam {
+		return &LimitError{
+			Status:  http.StatusTooManyRequests,
+			Message: fmt.Sprintf("実行待ち・実行中のジョブが %d 件あります。終了してから再度実行してください。", n),
+		}
+	}
+
+	if limits.Cooldown > 0 && last != nil {
+		wait := last.CreatedAt.Add(limits.Cooldown).Sub(now)
+		if wait > 0 {
+			// 秒の切り上げ
+			wait = (wait + time.Second - 1).Truncate(time.Second)
+			return &LimitError{
+				Status:     http.StatusTooManyRequests,
+				Message:    fmt.Sprintf("前回の実行から %d 秒間は実行できません。あと %d 秒お待ちください。", int(limits.Cooldown/time.Second), int(wait/time.Second)),
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	if limits.MaxQueuedJobs > 0 && queued >= limits.MaxQueuedJobs {
+		return &LimitError{
+			Status:  http.StatusServiceUnavailable,
+			Message: fmt.Sprintf("実行待ちのジョブが上限 (%d 件) に達しています。しばらくしてから再度実行してください。", limits.MaxQueuedJobs),
+		}
+	}
+	return nil
+}
+
+// ワーカーが落ちるなどして終わらなかったジョブ。開始してから jobStaleTimeout 経った実行中のものとする
+// 実行待ちのものは混んでいると長く待つだけなので数え続ける (残ってしまったものは job reset で中断する)
+func isStaleJob(j *Job, now time.Time) bool {
+	if j.Status != JobRunning || j.StartedAt == nil {
+		return false
+	}
+	return now.Sub(*j.StartedAt) > jobStaleTimeout
+}
+
+// 管理用のコマンド
+func runLimitCommand(db *gorm.DB, args []string) error {
+	switch len(args) {
+	case 0:
+		limits, err := loadSubmitLimits(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println("max_active_jobs_per_team:", limits.MaxActiveJobsPerTeam)
+		fmt.Println("submit_cooldown_seconds: ", int(limits.Cooldown/time.Second))
+		fmt.Println("max_queued_jobs:         ", limits.MaxQueuedJobs)
+		return nil
+
+	case 2:
+		name, value := args[0], args[1]
+		if _, ok := defaultSubmitLimits[name]; !ok {
+			var names []string
+			for n := range defaultSubmitLimits {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown limit %q: must be one of %v", name, names)
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("invalid value %q: must be a non-negative integer", value)
+		}
+		return db.Exec("INSERT INTO setting (name, value) VALUES (?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value)", name, value).Error
+
+	default:
+		return fmt.Errorf("usage: limit | limit <name> <value>")
+	}
+}
+
+// 管理用のコマンド
+// チームの実行待ち・実行中のジョブを中断にする。redis に残った実行待ちのジョブはワーカーが取り出しても実行しない
+func runJobCommand(db *gorm.DB, args []string) error {
+	if len(args) != 2 || args[0] != "reset" {
+		return fmt.Errorf("usage: job reset <team>")
+	}
+	res := db.Model(&Job{}).Where("team = ? AND status IN (?)", args[1], []string{JobQueued, JobRunning}).Updates(map[string]interface{}{
+		"status":      JobAborted,
+		"finished_at": time.Now(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	fmt.Println("reset", res.RowsAffected, "jobs")
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSubmitLimitError(t *testing.T) {
+	now := time.Date(2019, 10, 18, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time {
+		t := now.Add(-d)
+		return &t
+	}
+	queuedJob := func(created time.Duration) *Job {
+		return &Job{Status: JobQueued, CreatedAt: *ago(created)}
+	}
+	runningJob := func(created, started time.Duration) *Job {
+		return &Job{Status: JobRunning, CreatedAt: *ago(created), StartedAt: ago(started)}
+	}
+	limits := &SubmitLimits{MaxActiveJobsPerTeam: 1, Cooldown: time.Minute, MaxQueuedJobs: 10}
+
+	tests := []struct {
+		name   string
+		limits *SubmitLimits
+		active []*Job
+		last   *Job
+		queued int
+		status int
+		retry  time.Duration
+	}{
+		{"no jobs", limits, nil, nil, 0, 0, 0},
+		{"queued job", limits, []*Job{queuedJob(2 * time.Minute)}, nil, 1, http.StatusTooManyRequests, 0},
+		{"running job", limits, []*Job{runningJob(15*time.Minute, time.Minute)}, nil, 0, http.StatusTooManyRequests, 0},
+		{"stale running job", limits, []*Job{runningJob(20*time.Minute, 11*time.Minute)}, nil, 0, 0, 0},
+		// 実行待ちのものは長く待っていても数える
+		{"long queued job", limits, []*Job{queuedJob(30 * time.Minute)}, nil, 1, http.StatusTooManyRequests, 0},
+		{"unlimited active", &SubmitLimits{}, []*Job{queuedJob(time.Minute)}, nil, 100, 0, 0},
+		{"cooldown", limits, nil, &Job{CreatedAt: *ago(20*time.Second + time.Millisecond)}, 0, http.StatusTooManyRequests, 40 * time.Second},
+		{"cooldown passed", limits, nil, &Job{CreatedAt: *ago(time.Minute)}, 0, 0, 0},
+		{"queue full", limits, nil, nil, 10, http.StatusServiceUnavailable, 0},
+	}
+	for _, tt := range tests {
+		e := submitLimitError(tt.limits, tt.active, tt.last, tt.queued, now)
+		if tt.status == 0 {
+			if e != nil {
+				t.Errorf("%s: got %q, want no limit", tt.name, e.Message)
+			}
+			continue
+		}
+		if e == nil {
+			t.Errorf("%s: got no limit, want %d", tt.name, tt.status)
+			continue
+		}
+		if e.Status != tt.status || e.RetryAfter != tt.retry {
+			t.Errorf("%s: got status=%d retry=%v, want status=%d retry=%v", tt.name, e.Status, e.RetryAfter, tt.status, tt.retry)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +27,7 @@ type Bench struct {
 	Result     string    `gorm:"column:result" sql:"type:json"`
 	Created_at time.Time `gorm:"column:created_at"`
 	Resultfile string    `gorm:"column:resultfile"`
+	Node       string    `gorm:"column:node"`
 }
 
 func (t *Bench) TableName() string {
@@ -44,104 +49,381 @@ func checkIPaddressFormat(ipaddress string) bool {
 }
 
 func checkHostUp(ipaddress string) bool {
-	res := true
-	result, _ := exec.Command("sh", "-c", "nmap -sP "+ipaddress+" | grep \"Host is up\"").Output()
-	if string(result) == "" {
+	result, _ := exec.Command("nmap", "-sP", ipaddress).Output()
+	if !strings.Contains(string(result), "Host is up") {
 		log.Println(ipaddress + "is not up.")
-		res = false
+		return false
 	}
-	return res
+	return true
+}
+
+// チームが IP アドレスに対してベンチマークを実行できるか確認し、できない場合はステータスとメッセージを返す
+// URL のチーム名はログインしているチームと一致する必要がある (API では空)
+func checkTarget(team *Team, teamParam, ipaddress string) (int, string) {
+	if teamParam != "" && teamParam != team.Name {
+		return http.StatusForbidden, teamParam + "のページは表示できません。"
+	}
+	if !checkIPaddressFormat(ipaddress) {
+		return http.StatusBadRequest, ipaddress + "は不正な値です。"
+	}
+	if !team.AllowIPaddress(ipaddress) {
+		return http.StatusForbidden, ipaddress + "は" + team.Name + "に登録されていません。"
+	}
+	if !checkHostUp(ipaddress) {
+		return http.StatusOK, ipaddress + "は Host Up してません。"
+	}
+	return 0, ""
+}
+
+type BenchResult struct {
+	ID         int       `json:"id"`
+	Ipaddress  string    `json:"ipaddress"`
+	CreatedAt  time.Time `json:"created_at"`
+	Resultfile string    `json:"resultfile"`
+	Node       string    `json:"node"`
+	Results
+}
+
+func findResults(db *gorm.DB, team, ipaddress string) ([]Bench, []Results, error) {
+	var bench []Bench
+	if err := db.Select("*").Where("team = ? AND ipaddress = ?", team, ipaddress).Order("created_at DESC").Find(&bench).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var results []Results
+	for _, res := range bench {
+		var result Results
+		str := fmt.Sprintf("%v", res.Result)
+		if err := json.Unmarshal([]byte(str), &result); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, result)
+	}
+	return bench, results, nil
+}
+
+const (
+	// 時刻はワーカー (bench/main.go) と同じ loc で読み書きする。異なると created_at の比較がずれる
+	portalDSN = "hisucon:KCgC6LtWKp5tpKkW#@/hisucon2019_portal?charset=utf8mb4&parseTime=True&loc=Asia%2FTokyo"
+
+	// workermode のワーカー (bench -workermode) からのリクエストを受けるパス
+	workerPathPrefix = "/fC1iWrFEw3mD7NW8KYIu5cC5DzFDGf0a/"
+
+	resultDir = "/srv/bench/logs/"
+)
+
+// bench の cmd/bench/job.go と同期する事
+type WorkerJob struct {
+	ID      int64  `json:"id"`
+	TeamID  int    `json:"team_id"`
+	IPAddrs string `json:"ip_addrs"`
 }
 
 func main() {
+	db, err := gorm.Open("mysql", portalDSN)
+	if err != nil {
+		log.Fatal("DB connect error.", err)
+	}
+	defer db.Close()
+
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "team":
+			err = runTeamCommand(db, os.Args[2:])
+		case "limit":
+			err = runLimitCommand(db, os.Args[2:])
+		case "node":
+			err = runNodeCommand(db, os.Args[2:])
+		case "job":
+			err = runJobCommand(db, os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	initSessionSecret()
+	initLeaderboardConfig()
+
+	var queue JobQueue = newRedisJobQueue(db, "localhost:6379", "myqueue")
+
 	router := gin.Default()
 	currentDir, _ := os.Getwd()
 	router.LoadHTMLGlob(currentDir + "/templates/*.tmpl")
 
-	router.GET("/top/:team/:ipaddress", func(c *gin.Context) {
-		team := c.Param("team")
+	loginRoutes(router, db)
+
+	router.GET("/top/:team/:ipaddress", requireLogin(db), func(c *gin.Context) {
+		team := currentTeam(c)
 		ipaddress := c.Param("ipaddress")
-		checkIPaddressFormat(ipaddress)
-
-		if checkIPaddressFormat(ipaddress) {
-			if !checkHostUp(ipaddress) {
-				c.HTML(http.StatusOK, "error.tmpl", gin.H{
-					"message": ipaddress + "は Host Up してません。",
-				})
-				return
-			}
-		} else {
-			c.HTML(http.StatusBadRequest, "error.tmpl", gin.H{
-				"message": ipaddress + "は不正な値です。",
+
+		if status, message := checkTarget(team, c.Param("team"), ipaddress); status != 0 {
+			c.HTML(status, "error.tmpl", gin.H{
+				"message": message,
 			})
 			return
 		}
 
-		db, err := gorm.Open("mysql", "hisucon:KCgC6LtWKp5tpKkW#@/hisucon2019_portal?charset=utf8mb4&parseTime=True")
+		bench, results, err := findResults(db, team.Name, ipaddress)
 		if err != nil {
-			log.Fatal("DB connect error.", err)
-			return
-		}
-		defer db.Close()
-
-		var bench []Bench
-		db.Select("*").Where("team = ? AND ipaddress = ?", team, ipaddress).Order("created_at DESC").Find(&bench)
-
-		var results []Results
-		for _, res := range bench {
-			var result Results
-			str := fmt.Sprintf("%v", res.Result)
-			if err := json.Unmarshal([]byte(str), &result); err != nil {
-				log.Fatal(err)
-			}
-			results = append(results, result)
+			log.Fatal(err)
 		}
 
 		c.HTML(http.StatusOK, "index.tmpl", gin.H{
+			"team":    team,
 			"results": results,
 			"bench":   bench,
-			"url":     "/bench/" + team + "/" + ipaddress,
+			"points":  scorePoints(bench, results),
+			"url":     "/bench/" + team.Name + "/" + ipaddress,
+			"jobsUrl": "/jobs/" + team.Name + "/" + ipaddress,
+		})
+	})
+
+	router.GET("/leaderboard", requireLogin(db), func(c *gin.Context) {
+		board, err := buildLeaderboard(db, time.Now())
+		if err != nil {
+			fmt.Println("leaderboard error.", err)
+			c.HTML(http.StatusInternalServerError, "error.tmpl", gin.H{"message": "処理に失敗しました。"})
+			return
+		}
+		c.HTML(http.StatusOK, "leaderboard.tmpl", gin.H{
+			"team":  currentTeam(c),
+			"board": board,
 		})
 	})
 
-	router.GET("/bench/:team/:ipaddress", func(c *gin.Context) {
-		team := c.Param("team")
+	enqueueBench := func(c *gin.Context) {
+		team := currentTeam(c)
 		ipaddress := c.Param("ipaddress")
-		checkIPaddressFormat(ipaddress)
-
-		if checkIPaddressFormat(ipaddress) {
-			if !checkHostUp(ipaddress) {
-				c.HTML(http.StatusOK, "error.tmpl", gin.H{
-					"message": ipaddress + "は Host Up してません。",
-				})
-				return
+
+		if status, message := checkTarget(team, c.Param("team"), ipaddress); status != 0 {
+			if status == http.StatusOK {
+				status = http.StatusPreconditionFailed
 			}
-		} else {
-			c.HTML(http.StatusBadRequest, "error.tmpl", gin.H{
-				"message": ipaddress + "は不正な値です。",
-			})
+			c.JSON(status, message)
+			return
+		}
+
+		submitMtx.Lock()
+		defer submitMtx.Unlock()
+
+		limitErr, err := checkSubmitLimits(db, team, time.Now())
+		if err != nil {
+			fmt.Println("benchmark execute error.", err)
+			c.JSON(412, string("処理に失敗しました。"))
+			return
+		}
+		if limitErr != nil {
+			if limitErr.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(limitErr.RetryAfter/time.Second)))
+			}
+			c.JSON(limitErr.Status, limitErr.Message)
 			return
 		}
 
-		cmd := "redis-cli RPUSH resque:queue:myqueue '{\"class\":\"Hisucon2019\",\"args\":[\"" + team + "\",\"" + ipaddress + "\"]}'"
-		err := exec.Command("sh", "-c", cmd).Run()
+		job, err := queue.Enqueue(team.Name, ipaddress, team.BenchNode)
 		if err != nil {
 			fmt.Println("benchmark execute error.", err)
 			c.JSON(412, string("処理に失敗しました。"))
 
 		} else {
-			c.JSON(200, string("ok"))
+			c.JSON(200, job)
+		}
+	}
+
+	getJob := func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, string("不正なジョブ ID です。"))
+			return
 		}
 
+		job, err := queue.Get(id)
+		if err != nil {
+			fmt.Println("job status error.", err)
+			c.JSON(http.StatusInternalServerError, string("処理に失敗しました。"))
+			return
+		}
+		// 他のチームのジョブは見つからないものとして扱う
+		if job == nil || job.Team != currentTeam(c).Name {
+			c.JSON(http.StatusNotFound, string("ジョブが見つかりません。"))
+			return
+		}
+		c.JSON(200, job)
+	}
+
+	activeJobs := func(c *gin.Context) {
+		team := currentTeam(c)
+		ipaddress := c.Param("ipaddress")
+		if p := c.Param("team"); (p != "" && p != team.Name) || !team.AllowIPaddress(ipaddress) {
+			c.JSON(http.StatusForbidden, string("不正なリクエストです。"))
+			return
+		}
+
+		jobs, err := queue.Active(team.Name, ipaddress)
+		if err != nil {
+			fmt.Println("job status error.", err)
+			c.JSON(http.StatusInternalServerError, string("処理に失敗しました。"))
+			return
+		}
+		if jobs == nil {
+			jobs = []*Job{}
+		}
+		c.JSON(200, gin.H{"jobs": jobs})
+	}
+
+	router.POST("/bench/:team/:ipaddress", requireSession(db), enqueueBench)
+	router.GET("/job/:id", requireSession(db), getJob)
+	router.GET("/jobs/:team/:ipaddress", requireSession(db), activeJobs)
+
+	// 外部から使う JSON API
+	api := router.Group("/api", requireToken(db))
+	api.GET("/team", func(c *gin.Context) {
+		team := currentTeam(c)
+		c.JSON(200, gin.H{"name": team.Name, "ipaddresses": team.Ipaddresses})
+	})
+	api.GET("/leaderboard", func(c *gin.Context) {
+		board, err := buildLeaderboard(db, time.Now())
+		if err != nil {
+			fmt.Println("leaderboard error.", err)
+			c.JSON(http.StatusInternalServerError, string("処理に失敗しました。"))
+			return
+		}
+		c.JSON(200, board)
+	})
+	api.POST("/bench/:ipaddress", enqueueBench)
+	api.GET("/job/:id", getJob)
+	api.GET("/jobs/:ipaddress", activeJobs)
+	api.GET("/results/:ipaddress", func(c *gin.Context) {
+		team := currentTeam(c)
+		ipaddress := c.Param("ipaddress")
+		if !team.AllowIPaddress(ipaddress) {
+			c.JSON(http.StatusForbidden, string("不正なリクエストです。"))
+			return
+		}
+
+		bench, results, err := findResults(db, team.Name, ipaddress)
+		if err != nil {
+			fmt.Println("result error.", err)
+			c.JSON(http.StatusInternalServerError, string("処理に失敗しました。"))
+			return
+		}
+		list := []*BenchResult{}
+		for i, b := range bench {
+			list = append(list, &BenchResult{
+				ID:         b.Id,
+				Ipaddress:  b.Ipaddress,
+				CreatedAt:  b.Created_at,
+				Resultfile: b.Resultfile,
+				Node:       b.Node,
+				Results:    results[i],
+			})
+		}
+		c.JSON(200, gin.H{"results": list})
+	})
+
+	router.POST(workerPathPrefix+"job/new", func(c *gin.Context) {
+		node := c.PostForm("bench_node")
+		// workermode のワーカーは1つずつ実行する
+		if err := touchNode(db, node, 1); err != nil {
+			fmt.Println("node update error.", err)
+		}
+		if drained, err := isNodeDrained(db, node); err != nil || drained {
+			c.Status(http.StatusNoContent)
+			return
+		}
+
+		job, err := queue.Dequeue(node)
+		if err != nil {
+			fmt.Println("job dequeue error.", err)
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		if job == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		wj := &WorkerJob{ID: job.ID, IPAddrs: job.Ipaddress}
+		if team, err := findTeam(db, "name = ?", job.Team); err == nil && team != nil {
+			wj.TeamID = team.ID
+		}
+		c.JSON(200, wj)
+	})
+
+	router.POST(workerPathPrefix+"job/result", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Query("jobid"), 10, 64)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid jobid")
+			return
+		}
+		job, err := queue.Get(id)
+		if err != nil || job == nil {
+			c.String(http.StatusNotFound, "job not found")
+			return
+		}
+
+		status := JobDone
+		var result []byte
+		if c.Query("aborted") != "yes" {
+			if fh, err := c.FormFile("result"); err == nil {
+				if f, err := fh.Open(); err == nil {
+					result, _ = ioutil.ReadAll(f)
+					f.Close()
+				}
+			}
+		}
+		if len(result) == 0 || !json.Valid(result) {
+			status = JobAborted
+			result, _ = json.Marshal(gin.H{
+				"job_id":   fmt.Sprint(job.ID),
+				"ip_addrs": job.Ipaddress,
+				"pass":     false,
+				"score":    0,
+				"message":  "ベンチマークの実行に失敗しました。再実行を行ってください。",
+			})
+		}
+
+		name := job.Team + "-" + job.Ipaddress + "." + time.Now().Format("2006-01-02-15:04:05") + ".result.json"
+		bench := Bench{Team: job.Team, Ipaddress: job.Ipaddress, Result: string(result), Node: job.Node}
+		if status == JobDone {
+			if err := ioutil.WriteFile(resultDir+name, result, 0600); err != nil {
+				fmt.Println("result save error.", err)
+			} else {
+				bench.Resultfile = "/result/" + name
+			}
+		}
+		if err := db.Create(&bench).Error; err != nil {
+			fmt.Println("result save error.", err)
+			c.String(http.StatusInternalServerError, "failed")
+			return
+		}
+		if err := queue.Finish(job.ID, status, bench.Id); err != nil {
+			fmt.Println("job status error.", err)
+		}
+		c.String(200, "ok")
 	})
 
-	router.GET("/result/:resultfile", func(c *gin.Context) {
-		resultfile := c.Param("resultfile")
-		resultFile := "/srv/bench/logs/" + resultfile
-		cmd := "cat " + resultFile + " | jq ."
-		cmd = strings.Replace(cmd, ":", "\\:", -1)
-		result, _ := exec.Command("sh", "-c", cmd).Output()
-		c.String(200, string(result))
+	router.GET("/result/:resultfile", requireLogin(db), func(c *gin.Context) {
+		resultfile := filepath.Base(c.Param("resultfile"))
+
+		// 自分のチームの結果のみ表示する
+		var count int
+		db.Model(&Bench{}).Where("team = ? AND resultfile = ?", currentTeam(c).Name, "/result/"+resultfile).Count(&count)
+		if count == 0 {
+			c.HTML(http.StatusNotFound, "error.tmpl", gin.H{
+				"message": resultfile + "は見つかりません。",
+			})
+			return
+		}
+
+		b, _ := ioutil.ReadFile(resultDir + resultfile)
+		var result bytes.Buffer
+		json.Indent(&result, b, "", "  ")
+		c.String(200, result.String())
 	})
 
 	router.Run(":80")
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ベンチマークを実行するノード
+//
+// ワーカー (bench/main.go と bench -workermode) は起動時と定期的に bench_node に自分を登録する
+// チームを特定のノードに固定すると、そのチームのジョブはノード専用のキュー (myqueue:<node>) に積まれる
+// drain したノードは実行中のジョブが終わった後、新しいジョブを取らない
+//   cd /srv/webapp && go run *.go node
+//   cd /srv/webapp && go run *.go node drain <node>
+//   cd /srv/webapp && go run *.go node undrain <node>
+//   cd /srv/webapp && go run *.go team pin <team> <node>
+//   cd /srv/webapp && go run *.go team unpin <team>
+
+// この時間ハートビートがなければ停止しているものとして表示する
+const nodeAliveTimeout = time.Minute
+
+type BenchNode struct {
+	Name        string    `gorm:"column:name"`
+	Drain       bool      `gorm:"column:drain"`
+	Concurrency int       `gorm:"column:concurrency"`
+	LastSeen    time.Time `gorm:"column:last_seen"`
+}
+
+func (t *BenchNode) TableName() string {
+	return "bench_node"
+}
+
+// ノード専用のキューの名前
+func nodeQueue(queue, node string) string {
+	return queue + ":" + node
+}
+
+func touchNode(db *gorm.DB, name string, concurrency int) error {
+	return db.Exec("INSERT INTO bench_node (name, concurrency, last_seen) VALUES (?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE concurrency = VALUES(concurrency), last_seen = VALUES(last_seen)",
+		name, concurrency, time.Now()).Error
+}
+
+func isNodeDrained(db *gorm.DB, name string) (bool, error) {
+	node := new(BenchNode)
+	res := db.Where("name = ?", name).First(node)
+	if res.RecordNotFound() {
+		return false, nil
+	}
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return node.Drain, nil
+}
+
+// 管理用のコマンド
+func runNodeCommand(db *gorm.DB, args []string) error {
+	if len(args) == 0 {
+		var nodes []BenchNode
+		if err := db.Order("name").Find(&nodes).Error; err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			var running int
+			if err := db.Model(&Job{}).Where("node = ? AND status = ?", n.Name, JobRunning).Count(&running).Error; err != nil {
+				return err
+			}
+			state := "active"
+			if n.Drain {
+				state = "drain"
+			}
+			if time.Since(n.LastSeen) > nodeAliveTimeout {
+				state += " (down)"
+			}
+			fmt.Printf("%-24s %-14s concurrency=%d running=%d last_seen=%s\n",
+				n.Name, state, n.Concurrency, running, n.LastSeen.Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}
+
+	if len(args) != 2 || (args[0] != "drain" && args[0] != "undrain") {
+		return fmt.Errorf("usage: node | node drain <node> | node undrain <node>")
+	}
+	node := new(BenchNode)
+	res := db.Where("name = ?", args[1]).First(node)
+	if res.RecordNotFound() {
+		return fmt.Errorf("node %s not found", args[1])
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	return db.Model(&BenchNode{}).Where("name = ?", node.Name).Update("drain", args[0] == "drain").Error
+}
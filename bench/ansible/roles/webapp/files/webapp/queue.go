@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/jinzhu/gorm"
+)
+
+// ベンチマークのジョブキュー
+// ジョブの状態は job テーブルで管理し、実行待ちの順番は resque と同じ形式で redis に積む
+// ワーカー (bench/main.go) は goworker のまま取り出し、args の3番目のジョブ ID で job テーブルを更新する
+// workermode のワーカーは /<workerPathPrefix>job/new で取り出す
+// ノードに固定したチームのジョブはノード専用のキューに積み、そのノードのワーカーだけが取り出す
+
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+	JobAborted = "aborted"
+
+	// 開始時刻の目安を出す時に平均をとる直近のジョブ数
+	jobEstimateSamples = 20
+	// 実行したジョブがない時の1件あたりの時間の目安 (負荷走行 1 分 + 後片付け)
+	jobDefaultDuration = 80 * time.Second
+	// ベンチマーク (負荷走行 1 分 + 前後の処理) が確実に終わっている時間
+	// 開始してからこれを過ぎても終わらないジョブは止まったものとして、実行の制限で数えない
+	jobStaleTimeout = 10 * time.Minute
+)
+
+type Job struct {
+	ID         int64      `gorm:"column:id" json:"id"`
+	Team       string     `gorm:"column:team" json:"team"`
+	Ipaddress  string     `gorm:"column:ipaddress" json:"ipaddress"`
+	Status     string     `gorm:"column:status" json:"status"`
+	Queue      string     `gorm:"column:queue" json:"-"`
+	Node       string     `gorm:"column:node" json:"node"`
+	BenchID    *int       `gorm:"column:bench_id" json:"bench_id"`
+	CreatedAt  time.Time  `gorm:"column:created_at" json:"created_at"`
+	StartedAt  *time.Time `gorm:"column:started_at" json:"started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at" json:"finished_at"`
+
+	// 実行待ちの場合の順番 (1 始まり) と開始時刻の目安
+	Position       int        `gorm:"-" json:"position"`
+	EstimatedStart *time.Time `gorm:"-" json:"estimated_start,omitempty"`
+}
+
+func (t *Job) TableName() string {
+	return "job"
+}
+
+type JobQueue interface {
+	// node を指定した場合はそのノードでのみ実行する
+	Enqueue(team, ipaddress, node string) (*Job, error)
+	// ノード専用のキュー、共通のキューの順に実行待ちのジョブを1つ取り出して実行中にする。なければ nil
+	Dequeue(node string) (*Job, error)
+	Get(id int64) (*Job, error)
+	// チームの実行待ちと実行中のジョブ
+	Active(team, ipaddress string) ([]*Job, error)
+	Finish(id int64, status string, benchID int) error
+}
+
+type resqueJob struct {
+	Class string        `json:"class"`
+	Args  []interface{} `json:"args"`
+}
+
+type redisJobQueue struct {
+	db    *gorm.DB
+	pool  *redis.Pool
+	queue string
+}
+
+func newRedisJobQueue(db *gorm.DB, addr, queue string) *redisJobQueue {
+	return &redisJobQueue{
+		db: db,
+		pool: &redis.Pool{
+			MaxIdle:     3,
+			IdleTimeout: 240 * time.Second,
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", addr)
+			},
+		},
+		queue: queue,
+	}
+}
+
+func (q *redisJobQueue) Enqueue(team, ipaddress, node string) (*Job, error) {
+	queue := q.queue
+	if node != "" {
+		queue = nodeQueue(q.queue, node)
+	}
+	job := &Job{
+		Team:      team,
+		Ipaddress: ipaddress,
+		Status:    JobQueued,
+		Queue:     queue,
+		CreatedAt: time.Now(),
+	}
+	if err := q.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(&resqueJob{
+		Class: "Hisucon2019",
+		Args:  []interface{}{team, ipaddress, job.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	if node != "" {
+		// goworker はキューの一覧を見ないが、resque の管理画面で見えるように登録しておく
+		conn.Do("SADD", "resque:queues", queue)
+	}
+	if _, err := conn.Do("RPUSH", "resque:queue:"+queue, payload); err != nil {
+		q.db.Model(job).Update("status", JobFailed)
+		return nil, err
+	}
+
+	if err := q.fillPosition(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (q *redisJobQueue) Dequeue(node string) (*Job, error) {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	queues := []string{nodeQueue(q.queue, node), q.queue}
+	for {
+		var payload string
+		var err error
+		for _, queue := range queues {
+			payload, err = redis.String(conn.Do("LPOP", "resque:queue:"+queue))
+			if err != redis.ErrNil {
+				break
+			}
+		}
+		if err == redis.ErrNil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var rj resqueJob
+		dec := json.NewDecoder(strings.NewReader(payload))
+		dec.UseNumber()
+		if err := dec.Decode(&rj); err != nil || len(rj.Args) < 3 {
+			// ジョブ ID のない古い形式は workermode では扱えないので捨てる
+			continue
+		}
+		id, err := strconv.ParseInt(fmt.Sprint(rj.Args[2]), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		now := time.Now()
+		res := q.db.Model(&Job{}).Where("id = ? AND status = ?", id, JobQueued).Updates(map[string]interface{}{
+			"status":     JobRunning,
+			"node":       node,
+			"started_at": now,
+		})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		// job reset で中断したジョブは実行しない
+		if res.RowsAffected == 0 {
+			continue
+		}
+		return q.Get(id)
+	}
+}
+
+func (q *redisJobQueue) Get(id int64) (*Job, error) {
+	job := new(Job)
+	res := q.db.Where("id = ?", id).First(job)
+	if res.RecordNotFound() {
+		return nil, nil
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if err := q.fillPosition(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (q *redisJobQueue) Active(team, ipaddress string) ([]*Job, error) {
+	var jobs []*Job
+	err := q.db.Where("team = ? AND ipaddress = ? AND status IN (?)", team, ipaddress, []string{JobQueued, JobRunning}).
+		Order("id").Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		if err := q.fillPosition(job); err != nil {
+			return nil, err
+		}
+	}
+	return jobs, nil
+}
+
+func (q *redisJobQueue) Finish(id int64, status string, benchID int) error {
+	now := time.Now()
+	return q.db.Model(&Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"bench_id":    benchID,
+		"finished_at": now,
+	}).Error
+}
+
+// 実行待ちのジョブに順番と開始時刻の目安を入れる
+// 順番は同じキューに積まれたジョブの中で数える。ノード専用のキューは他のノードのジョブを待たない
+// 目安は 前に待っているジョブ数 + 実行中のジョブ数 (ノード専用のキューはそのノードで実行中のもの) に
+// 直近のジョブの平均実行時間を掛けたもの
+func (q *redisJobQueue) fillPosition(job *Job) error {
+	if job.Status != JobQueued {
+		return nil
+	}
+
+	var ahead, running int
+	if err := q.db.Model(&Job{}).Where("queue = ? AND status = ? AND id < ?", job.Queue, JobQueued, job.ID).Count(&ahead).Error; err != nil {
+		return err
+	}
+	runningJobs := q.db.Model(&Job{}).Where("status = ?", JobRunning)
+	if prefix := q.queue + ":"; strings.HasPrefix(job.Queue, prefix) {
+		runningJobs = runningJobs.Where("node = ?", job.Queue[len(prefix):])
+	}
+	if err := runningJobs.Count(&running).Error; err != nil {
+		return err
+	}
+	job.Position = ahead + 1
+
+	avg, err := q.averageDuration()
+	if err != nil {
+		return err
+	}
+	t := time.Now().Add(time.Duration(ahead+running) * avg)
+	job.EstimatedStart = &t
+	return nil
+}
+
+func (q *redisJobQueue) averageDuration() (time.Duration, error) {
+	var jobs []*Job
+	err := q.db.Where("status = ? AND started_at IS NOT NULL AND finished_at IS NOT NULL", JobDone).
+		Order("id DESC").Limit(jobEstimateSamples).Find(&jobs).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(jobs) == 0 {
+		return jobDefaultDuration, nil
+	}
+
+	var sum time.Duration
+	for _, j := range jobs {
+		sum += j.FinishedAt.Sub(*j.StartedAt)
+	}
+	return sum / time.Duration(len(jobs)), nil
+}